@@ -0,0 +1,337 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+type AuditConfig struct {
+	// Path of the audit log. Defaults to audit.jsonl in the data directory.
+	Path string `json:"path"`
+
+	// Operator is the name that is written to the audit log. Defaults to
+	// the name of the user that runs the program.
+	Operator string `json:"operator"`
+}
+
+// AuditEntry is a single line in the audit log.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Operator  string    `json:"operator"`
+	Server    string    `json:"server"`
+	Command   string    `json:"command"`
+	Action    string    `json:"action"`
+	Args      []string  `json:"args,omitempty"`
+	PlayerIDs []string  `json:"player_ids,omitempty"`
+	Result    string    `json:"result"`
+}
+
+// AuditLog appends a JSON line to a file for every call that changes the
+// state of the server.
+type AuditLog struct {
+	path     string
+	operator string
+	server   string
+
+	mutex    sync.Mutex
+	handlers []func(AuditEntry)
+	onError  func(error)
+}
+
+func NewAuditLog(path, operator, server string) *AuditLog {
+	if operator == "" {
+		operator = os.Getenv("USER")
+		if u, err := user.Current(); err == nil {
+			operator = u.Username
+		}
+	}
+	return &AuditLog{
+		path:     path,
+		operator: operator,
+		server:   server,
+	}
+}
+
+// Subscribe registers a function that is called for every entry after it was
+// written.
+func (l *AuditLog) Subscribe(handler func(AuditEntry)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.handlers = append(l.handlers, handler)
+}
+
+// OnError sets a function that is called when an action succeeded, but
+// couldn't be written to the log. By default, a warning is printed to stderr.
+func (l *AuditLog) OnError(handler func(error)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.onError = handler
+}
+
+func (l *AuditLog) warn(err error) {
+	l.mutex.Lock()
+	onError := l.onError
+	l.mutex.Unlock()
+
+	if onError != nil {
+		onError(err)
+	} else {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+func (l *AuditLog) Record(entry AuditEntry) error {
+	entry.Time = time.Now()
+	entry.Operator = l.operator
+	entry.Server = l.server
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mutex.Lock()
+	handlers := l.handlers
+	err = l.write(data)
+	l.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, handler := range handlers {
+		handler(entry)
+	}
+	return nil
+}
+
+// write appends a line to the file. The mutex must be held.
+func (l *AuditLog) write(data []byte) error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Entries returns all entries that match filter, oldest first.
+func (l *AuditLog) Entries(filter func(AuditEntry) bool) ([]AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt audit log: %w", err)
+		}
+		if filter(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// Client wraps a client so that every call that changes the state of the
+// server is recorded. command describes what caused the calls, for example
+// the line that was typed into the REPL.
+func (l *AuditLog) Client(client Client, command string) Client {
+	return &auditedClient{client, l, command}
+}
+
+type auditedClient struct {
+	Client
+	log     *AuditLog
+	command string
+}
+
+func (c *auditedClient) record(action string, args []string, playerIDs []string, result string, err error) error {
+	if err != nil {
+		result = "error: " + err.Error()
+	} else if result == "" {
+		result = "ok"
+	}
+
+	auditErr := c.log.Record(AuditEntry{
+		Command:   c.command,
+		Action:    action,
+		Args:      args,
+		PlayerIDs: playerIDs,
+		Result:    result,
+	})
+	// The action already happened, so failing here would only make it look
+	// like it didn't
+	if auditErr != nil {
+		c.log.warn(fmt.Errorf("cannot write audit log: %w", auditErr))
+	}
+	return err
+}
+
+func (c *auditedClient) recordToggle(action string, state bool, err error) (bool, error) {
+	return state, c.record(action, nil, nil, onOff(state), err)
+}
+
+func (c *auditedClient) Announce(message string) error {
+	err := c.Client.Announce(message)
+	return c.record("Announce", []string{message}, nil, "", err)
+}
+
+func (c *auditedClient) SendDirectMessage(playerID, message string) error {
+	err := c.Client.SendDirectMessage(playerID, message)
+	return c.record("SendDirectMessage", []string{playerID, message}, []string{playerID}, "", err)
+}
+
+func (c *auditedClient) KickPlayer(playerID, reason string) error {
+	err := c.Client.KickPlayer(playerID, reason)
+	return c.record("KickPlayer", []string{playerID, reason}, []string{playerID}, "", err)
+}
+
+func (c *auditedClient) WipeCorpses() error {
+	err := c.Client.WipeCorpses()
+	return c.record("WipeCorpses", nil, nil, "", err)
+}
+
+func (c *auditedClient) UpdatePlayables(classes []rcon.DinoClass) error {
+	err := c.Client.UpdatePlayables(classes)
+	args := make([]string, len(classes))
+	for i, class := range classes {
+		args[i] = string(class)
+	}
+	return c.record("UpdatePlayables", args, nil, "", err)
+}
+
+func (c *auditedClient) ToggleWhitelist() (bool, error) {
+	state, err := c.Client.ToggleWhitelist()
+	return c.recordToggle("ToggleWhitelist", state, err)
+}
+
+func (c *auditedClient) AddWhitelistID(playerID ...string) error {
+	err := c.Client.AddWhitelistID(playerID...)
+	return c.record("AddWhitelistID", playerID, playerID, "", err)
+}
+
+func (c *auditedClient) RemoveWhitelistID(playerID ...string) error {
+	err := c.Client.RemoveWhitelistID(playerID...)
+	return c.record("RemoveWhitelistID", playerID, playerID, "", err)
+}
+
+func (c *auditedClient) ToggleGlobalChat() (bool, error) {
+	state, err := c.Client.ToggleGlobalChat()
+	return c.recordToggle("ToggleGlobalChat", state, err)
+}
+
+func (c *auditedClient) ToggleHumans() (bool, error) {
+	state, err := c.Client.ToggleHumans()
+	return c.recordToggle("ToggleHumans", state, err)
+}
+
+func (c *auditedClient) ToggleAI() (bool, error) {
+	state, err := c.Client.ToggleAI()
+	return c.recordToggle("ToggleAI", state, err)
+}
+
+func (c *auditedClient) DisableAIClasses(classes []rcon.AIClass) error {
+	err := c.Client.DisableAIClasses(classes)
+	args := make([]string, len(classes))
+	for i, class := range classes {
+		args[i] = string(class)
+	}
+	return c.record("DisableAIClasses", args, nil, "", err)
+}
+
+func (c *auditedClient) SetAIDensity(density float32) error {
+	err := c.Client.SetAIDensity(density)
+	return c.record("SetAIDensity", []string{fmt.Sprintf("%.3f", density)}, nil, "", err)
+}
+
+// ExecCommand is always recorded, because there is no way to know whether an
+// unknown command changes anything. Bans have no method of their own, but
+// are recorded like kicks, so that they can be found in the log.
+func (c *auditedClient) ExecCommand(command byte, params ...string) (string, error) {
+	response, err := c.Client.ExecCommand(command, params...)
+	if rcon.MessageType(command) == rcon.BanPlayer && len(params) == 4 {
+		return response, c.record("BanPlayer", params, params[1:2], "", err)
+	}
+	args := append([]string{fmt.Sprintf("%02x", command)}, params...)
+	return response, c.record("ExecCommand", args, nil, "", err)
+}
+
+// AuditFilter selects entries of the audit log. Empty fields match
+// everything.
+type AuditFilter struct {
+	Since    time.Time
+	Operator string
+	Action   string
+	Player   string
+}
+
+func (f AuditFilter) Match(entry AuditEntry) bool {
+	if entry.Time.Before(f.Since) {
+		return false
+	}
+	if f.Operator != "" && !strings.EqualFold(entry.Operator, f.Operator) {
+		return false
+	}
+	if f.Action != "" && !strings.Contains(strings.ToLower(entry.Action), strings.ToLower(f.Action)) {
+		return false
+	}
+	if f.Player != "" && !slices.Contains(entry.PlayerIDs, f.Player) {
+		return false
+	}
+	return true
+}
+
+func onOff(state bool) string {
+	if state {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,116 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAuditedClient(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	client := repl.wrapClient("test")
+
+	// Reading doesn't end up in the audit log
+	if _, err := client.GetPlayerList(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.KickPlayer(bob.ID, "Bye"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ToggleWhitelist(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := repl.auditLog.Entries(AuditFilter{}.Match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+
+	kick := entries[0]
+	if kick.Action != "KickPlayer" || kick.Operator != "tester" || kick.Command != "test" || kick.Result != "ok" {
+		t.Errorf("unexpected kick entry %+v", kick)
+	}
+	if !slices.Equal(kick.PlayerIDs, []string{bob.ID}) {
+		t.Errorf("got player IDs %v, want [%s]", kick.PlayerIDs, bob.ID)
+	}
+
+	toggle := entries[1]
+	if toggle.Action != "ToggleWhitelist" || toggle.Result != "on" {
+		t.Errorf("unexpected toggle entry %+v", toggle)
+	}
+}
+
+func TestAuditFilter(t *testing.T) {
+	log := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"), "alice", "localhost:8888")
+	for _, entry := range []AuditEntry{
+		{Action: "KickPlayer", PlayerIDs: []string{"1"}},
+		{Action: "Announce"},
+		{Action: "AddWhitelistID", PlayerIDs: []string{"1", "2"}},
+	} {
+		if err := log.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   int
+	}{
+		{"everything", AuditFilter{}, 3},
+		{"player", AuditFilter{Player: "1"}, 2},
+		{"action", AuditFilter{Action: "kick"}, 1},
+		{"operator", AuditFilter{Operator: "Alice"}, 3},
+		{"other operator", AuditFilter{Operator: "bob"}, 0},
+		{"future", AuditFilter{Since: time.Now().Add(time.Hour)}, 0},
+	}
+
+	for _, tt := range tests {
+		entries, err := log.Entries(tt.filter.Match)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("%s: got %d entries, want %d", tt.name, len(entries), tt.want)
+		}
+	}
+}
+
+func TestAuditWriteFailureIsWarning(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	auditLog := NewAuditLog(filepath.Join(t.TempDir(), "missing", "audit.jsonl"), "tester", server.Addr())
+	var warnings []error
+	auditLog.OnError(func(err error) {
+		warnings = append(warnings, err)
+	})
+	client := auditLog.Client(repl.wrapClient("test"), "test")
+
+	if err := client.KickPlayer(bob.ID, "Bye"); err != nil {
+		t.Errorf("the kick worked, so it should not fail: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("got warnings %v, want 1", warnings)
+	}
+}
@@ -0,0 +1,396 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BridgeAnnounce = "announce"
+	BridgeDM       = "dm"
+)
+
+const (
+	maxBridgeQueue    = 100
+	maxBridgeAttempts = 3
+)
+
+var ErrBridgeQueueFull = errors.New("the queue is full")
+
+type BridgeConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// File is a file that other programs append messages to, one JSON
+	// object per line. Only lines that are added while PteroPrompt is running
+	// are read.
+	File string `json:"file"`
+
+	// Listen is the address of an HTTP server that accepts messages with
+	// POST requests. Requests must send Token as a bearer token if it is set.
+	Listen string `json:"listen"`
+	Token  string `json:"token"`
+
+	// Socket is the path of a Unix socket that accepts messages, one JSON
+	// object per line.
+	Socket string `json:"socket"`
+
+	// MessagesPerMinute limits how many messages are sent to the server.
+	// Long messages are split into several ones. Defaults to 10.
+	MessagesPerMinute int `json:"messages_per_minute"`
+}
+
+// BridgeMessage is a message that another program wants to send.
+type BridgeMessage struct {
+	// Type is announce or dm.
+	Type string `json:"type"`
+
+	// Player is the name or ID of the recipient of a dm.
+	Player string `json:"player"`
+	Text   string `json:"text"`
+}
+
+func (m *BridgeMessage) validate() error {
+	switch m.Type {
+	case BridgeAnnounce:
+	case BridgeDM:
+		if m.Player == "" {
+			return errors.New("missing player")
+		}
+	default:
+		return fmt.Errorf("unknown type \"%s\"", m.Type)
+	}
+	if strings.TrimSpace(m.Text) == "" {
+		return errors.New("missing text")
+	}
+	return nil
+}
+
+type bridgeItem struct {
+	BridgeMessage
+	attempts int
+}
+
+// Bridge forwards messages from other programs to the server, so that they
+// can send announcements without knowing the RCON password.
+type Bridge struct {
+	*Poller
+	client   Client
+	config   BridgeConfig
+	messages MessagesConfig
+	log      func(string)
+
+	mutex  sync.Mutex
+	queue  []bridgeItem
+	offset int64
+
+	closers []io.Closer
+}
+
+func NewBridge(client Client, config BridgeConfig, messages MessagesConfig, log func(string)) *Bridge {
+	rate := config.MessagesPerMinute
+	if rate <= 0 {
+		rate = 10
+	}
+
+	b := &Bridge{
+		client:   client,
+		config:   config,
+		messages: messages,
+		log:      log,
+		offset:   -1,
+	}
+	b.Poller = NewPoller(time.Minute/time.Duration(rate), b.Poll)
+	return b
+}
+
+// Enqueue validates a message and adds it to the queue. Long messages are
+// split into several parts.
+func (b *Bridge) Enqueue(msg BridgeMessage) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	parts := b.messages.Split(msg.Text)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.queue)+len(parts) > maxBridgeQueue {
+		return ErrBridgeQueueFull
+	}
+	for _, part := range parts {
+		item := bridgeItem{BridgeMessage: msg}
+		item.Text = part
+		b.queue = append(b.queue, item)
+	}
+	return nil
+}
+
+// Pending returns the number of messages that have not been sent yet.
+func (b *Bridge) Pending() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.queue)
+}
+
+// Poll reads new messages from the inbox file and sends the next message in
+// the queue.
+func (b *Bridge) Poll() error {
+	if b.config.File != "" {
+		if err := b.readFile(); err != nil {
+			b.log(fmt.Sprintf("Cannot read bridge inbox: %v", err))
+		}
+	}
+
+	b.mutex.Lock()
+	if len(b.queue) == 0 {
+		b.mutex.Unlock()
+		return nil
+	}
+	item := b.queue[0]
+	b.queue = b.queue[1:]
+	b.mutex.Unlock()
+
+	err := b.send(item.BridgeMessage)
+	if errors.Is(err, ErrPlayerNotFound) || errors.Is(err, ErrAmbiguousName) {
+		b.log(fmt.Sprintf("Cannot forward message to %s: %v", item.Player, err))
+		return nil
+	}
+	if err != nil {
+		item.attempts++
+		if item.attempts < maxBridgeAttempts {
+			b.mutex.Lock()
+			b.queue = append([]bridgeItem{item}, b.queue...)
+			b.mutex.Unlock()
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *Bridge) send(msg BridgeMessage) error {
+	switch msg.Type {
+	case BridgeAnnounce:
+		return b.client.Announce(msg.Text)
+	case BridgeDM:
+		playerID, err := ResolvePlayerName(b.client, msg.Player)
+		if errors.Is(err, ErrPlayerNotFound) && isPlayerID(msg.Player) {
+			playerID, err = msg.Player, nil
+		}
+		if err != nil {
+			return err
+		}
+		return b.client.SendDirectMessage(playerID, msg.Text)
+	}
+	return nil
+}
+
+// isPlayerID returns true if s looks like a Steam or EOS ID.
+func isPlayerID(s string) bool {
+	if len(s) < 16 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// readFile queues every line that was added to the inbox file since the last
+// call. Lines that were in the file when the bridge started are ignored.
+func (b *Bridge) readFile() error {
+	f, err := os.Open(b.config.File)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			b.offset = 0
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if b.offset < 0 {
+		b.offset = info.Size()
+		return nil
+	}
+	// The file was truncated or replaced
+	if info.Size() < b.offset {
+		b.offset = 0
+	}
+
+	if _, err := f.Seek(b.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// Incomplete lines are read again next time
+			break
+		}
+		b.offset += int64(len(line))
+		if err := b.enqueueJSON(line); err != nil {
+			b.log(fmt.Sprintf("Ignoring bridge message: %v", err))
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) enqueueJSON(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	var msg BridgeMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return err
+	}
+	return b.Enqueue(msg)
+}
+
+// Listen starts the HTTP server and the Unix socket, if they are configured.
+func (b *Bridge) Listen() error {
+	if b.config.Listen != "" {
+		// Anyone who can reach the inbox can send messages to all players
+		if b.config.Token == "" && !isLoopback(b.config.Listen) {
+			return fmt.Errorf("a token is required to listen on %s, or use a loopback address like 127.0.0.1", b.config.Listen)
+		}
+		l, err := net.Listen("tcp", b.config.Listen)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: b, ReadHeaderTimeout: 10 * time.Second}
+		b.closers = append(b.closers, server)
+		go server.Serve(l)
+	}
+
+	if b.config.Socket != "" {
+		// A socket that is left over from a crash would make Listen fail,
+		// but anything else at that path is not ours to remove
+		if info, err := os.Lstat(b.config.Socket); err == nil {
+			if info.Mode().Type() != fs.ModeSocket {
+				b.Close()
+				return fmt.Errorf("%s exists and is not a socket", b.config.Socket)
+			}
+			os.Remove(b.config.Socket)
+		}
+		l, err := net.Listen("unix", b.config.Socket)
+		if err != nil {
+			b.Close()
+			return err
+		}
+		b.closers = append(b.closers, l)
+		go b.serveSocket(l)
+	}
+
+	return nil
+}
+
+// isLoopback reports whether address only accepts connections from the same
+// machine.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Close stops the HTTP server and the Unix socket.
+func (b *Bridge) Close() {
+	for _, c := range b.closers {
+		c.Close()
+	}
+	b.closers = nil
+}
+
+// ServeHTTP accepts a single message as JSON in the body of a POST request.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if b.config.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(b.config.Token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var msg BridgeMessage
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := b.Enqueue(msg)
+	switch {
+	case errors.Is(err, ErrBridgeQueueFull):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// serveSocket accepts messages on a Unix socket and answers every line with
+// "ok" or an error.
+func (b *Bridge) serveSocket(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				if err := b.enqueueJSON(scanner.Text()); err != nil {
+					fmt.Fprintf(conn, "error: %v\n", err)
+				} else {
+					fmt.Fprintln(conn, "ok")
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+)
+
+func newTestBridge(t *testing.T, config BridgeConfig) (*Bridge, *fakeserver.Server) {
+	t.Helper()
+
+	server, err := fakeserver.Start("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	server.SetPlayers(alice, bob)
+
+	client, err := DialReconnecting(server.Addr(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	b := NewBridge(client, config, MessagesConfig{}, func(string) {})
+	t.Cleanup(b.Close)
+	return b, server
+}
+
+// drain sends every message in the queue.
+func drain(t *testing.T, b *Bridge) {
+	t.Helper()
+	for b.Pending() > 0 {
+		if err := b.Poll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBridgeHTTP(t *testing.T) {
+	b, server := newTestBridge(t, BridgeConfig{Token: "hunter2"})
+	endpoint := httptest.NewServer(b)
+	defer endpoint.Close()
+
+	post := func(token, body string) int {
+		req, _ := http.NewRequest(http.MethodPost, endpoint.URL, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("wrong", `{"type":"announce","text":"Hi"}`); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: got %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := post("hunter2", `{"type":"dm","text":"Hi"}`); code != http.StatusBadRequest {
+		t.Errorf("dm without player: got %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := post("hunter2", `{"type":"announce","text":"Event starting"}`); code != http.StatusAccepted {
+		t.Errorf("announce: got %d, want %d", code, http.StatusAccepted)
+	}
+	if code := post("hunter2", `{"type":"dm","player":"Bob","text":"Hello"}`); code != http.StatusAccepted {
+		t.Errorf("dm: got %d, want %d", code, http.StatusAccepted)
+	}
+
+	drain(t, b)
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Announcements) != 1 || server.Announcements[0] != "Event starting" {
+		t.Errorf("announcements = %q", server.Announcements)
+	}
+	if len(server.DirectMessages) != 1 || server.DirectMessages[0] != (fakeserver.Message{PlayerID: bob.ID, Text: "Hello"}) {
+		t.Errorf("direct messages = %v", server.DirectMessages)
+	}
+}
+
+func TestBridgeSplitsLongMessages(t *testing.T) {
+	b, server := newTestBridge(t, BridgeConfig{})
+
+	text := strings.Repeat("a", maxMessageLength+10)
+	if err := b.Enqueue(BridgeMessage{Type: BridgeAnnounce, Text: text}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Pending() != 2 {
+		t.Fatalf("pending = %d, want 2", b.Pending())
+	}
+	drain(t, b)
+
+	server.Lock()
+	defer server.Unlock()
+	if strings.Join(server.Announcements, "") != text {
+		t.Errorf("announcements = %q", server.Announcements)
+	}
+}
+
+func TestBridgeQueueLimit(t *testing.T) {
+	b, _ := newTestBridge(t, BridgeConfig{})
+
+	for range maxBridgeQueue {
+		if err := b.Enqueue(BridgeMessage{Type: BridgeAnnounce, Text: "Hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Enqueue(BridgeMessage{Type: BridgeAnnounce, Text: "Hi"}); err != ErrBridgeQueueFull {
+		t.Errorf("got %v, want %v", err, ErrBridgeQueueFull)
+	}
+}
+
+func TestBridgeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inbox.jsonl")
+	os.WriteFile(path, []byte(`{"type":"announce","text":"Old"}`+"\n"), 0644)
+
+	b, server := newTestBridge(t, BridgeConfig{File: path})
+
+	// Messages that were already in the file are skipped
+	drain(t, b)
+	if err := b.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"announce","text":"New"}` + "\n" + `{"type":"announce",`)
+	f.Close()
+
+	if err := b.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, b)
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Announcements) != 1 || server.Announcements[0] != "New" {
+		t.Errorf("announcements = %q", server.Announcements)
+	}
+}
+
+func TestBridgeSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.sock")
+	b, server := newTestBridge(t, BridgeConfig{Socket: path})
+	if err := b.Listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	conn.Write([]byte(`{"type":"dm","player":"Alice","text":"Hi"}` + "\n"))
+	if reply, _ := reader.ReadString('\n'); reply != "ok\n" {
+		t.Errorf("reply = %q, want ok", reply)
+	}
+	conn.Write([]byte(`{"type":"shout","text":"Hi"}` + "\n"))
+	if reply, _ := reader.ReadString('\n'); !strings.HasPrefix(reply, "error: ") {
+		t.Errorf("reply = %q, want an error", reply)
+	}
+
+	drain(t, b)
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.DirectMessages) != 1 || server.DirectMessages[0].PlayerID != alice.ID {
+		t.Errorf("direct messages = %v", server.DirectMessages)
+	}
+}
+
+func TestBridgeNeedsTokenOutsideLoopback(t *testing.T) {
+	b, _ := newTestBridge(t, BridgeConfig{Listen: ":0"})
+	if err := b.Listen(); err == nil {
+		t.Error("expected an error without a token on all interfaces")
+	}
+
+	b, _ = newTestBridge(t, BridgeConfig{Listen: "127.0.0.1:0"})
+	if err := b.Listen(); err != nil {
+		t.Errorf("loopback addresses don't need a token: %v", err)
+	}
+}
+
+func TestBridgeKeepsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.sock")
+	if err := os.WriteFile(path, []byte("important"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := newTestBridge(t, BridgeConfig{Socket: path})
+	if err := b.Listen(); err == nil {
+		t.Error("expected an error for a file that is not a socket")
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != "important" {
+		t.Errorf("the file was changed: %q, %v", content, err)
+	}
+}
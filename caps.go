@@ -0,0 +1,307 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+type CapsConfig struct {
+	IntervalSeconds int `json:"interval_seconds"`
+
+	// Announce makes the cap watcher announce when a class becomes full or
+	// available again.
+	Announce bool `json:"announce"`
+
+	// DryRun is set by --dry-run. Changes are kept in memory and not saved.
+	DryRun bool `json:"-"`
+}
+
+// capsState is what the cap watcher stores on disk.
+type capsState struct {
+	Caps map[rcon.DinoClass]int `json:"caps"`
+
+	// Allowed is the list of classes that were allowed with the classes
+	// command. Nil means that nobody has set them yet.
+	Allowed []rcon.DinoClass `json:"allowed"`
+}
+
+// ErrAllowedUnknown is returned when caps would have to change the playables
+// before the allowed classes were set with the classes command. The server
+// cannot be asked which classes are allowed, so the caps would have to guess.
+var ErrAllowedUnknown = errors.New("the allowed classes are unknown")
+
+// CapWatcher limits the number of players per class. Evrima has no such
+// setting, so the watcher counts the classes of all players and removes a
+// class from the allowed playables while it is full.
+type CapWatcher struct {
+	*Poller
+	client Client
+	config CapsConfig
+	path   string
+	log    func(string)
+
+	mutex sync.Mutex
+	state capsState
+	full  map[rcon.DinoClass]bool
+}
+
+// NewCapWatcher creates a cap watcher that stores its caps in the file at
+// path.
+func NewCapWatcher(client Client, config CapsConfig, path string, log func(string)) (*CapWatcher, error) {
+	interval := time.Duration(config.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	w := &CapWatcher{
+		client: client,
+		config: config,
+		path:   path,
+		log:    log,
+		state:  capsState{Caps: make(map[rcon.DinoClass]int)},
+		full:   make(map[rcon.DinoClass]bool),
+	}
+	w.Poller = NewPoller(interval, w.Poll)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return w, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &w.state)
+	if err != nil {
+		return nil, err
+	}
+	if w.state.Caps == nil {
+		w.state.Caps = make(map[rcon.DinoClass]int)
+	}
+
+	return w, nil
+}
+
+// Caps returns the configured caps.
+func (w *CapWatcher) Caps() map[rcon.DinoClass]int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return maps.Clone(w.state.Caps)
+}
+
+// IsFull reports whether a class is currently removed from the playables
+// because it reached its cap.
+func (w *CapWatcher) IsFull(class rcon.DinoClass) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.full[class]
+}
+
+// SetCap limits the number of players of a class and starts the watcher. It
+// returns ErrAllowedUnknown if the allowed classes haven't been set yet.
+func (w *CapWatcher) SetCap(class rcon.DinoClass, limit int) error {
+	w.mutex.Lock()
+	if w.state.Allowed == nil {
+		w.mutex.Unlock()
+		return ErrAllowedUnknown
+	}
+	w.state.Caps[class] = limit
+	w.mutex.Unlock()
+
+	err := w.save()
+	if err != nil {
+		return err
+	}
+
+	w.Start()
+	return nil
+}
+
+// RemoveCap removes the limit of a class. If it was full, it becomes playable
+// again right away, using client to update the playables. The watcher stops
+// when no caps are left.
+func (w *CapWatcher) RemoveCap(client Client, class rcon.DinoClass) error {
+	w.mutex.Lock()
+	delete(w.state.Caps, class)
+	wasFull := w.full[class]
+	delete(w.full, class)
+	empty := len(w.state.Caps) == 0
+	w.mutex.Unlock()
+
+	err := w.save()
+	if err != nil {
+		return err
+	}
+
+	if empty {
+		w.Stop()
+	}
+
+	if wasFull {
+		return w.update(client, []rcon.DinoClass{class}, nil)
+	}
+	return nil
+}
+
+// Allowed returns the classes that were last allowed with SetAllowed, or nil
+// if they were never set. The server cannot be asked which classes are
+// allowed, so this may be wrong if someone changed them in another way.
+func (w *CapWatcher) Allowed() []rcon.DinoClass {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return slices.Clone(w.state.Allowed)
+}
+
+// SetAllowed sets the list of classes that are allowed on the server, using
+// client to update the playables. Classes that are full stay disabled until a
+// slot frees up.
+func (w *CapWatcher) SetAllowed(client Client, classes []rcon.DinoClass) error {
+	w.mutex.Lock()
+	w.state.Allowed = slices.Clone(classes)
+	w.mutex.Unlock()
+
+	err := w.save()
+	if err != nil {
+		return err
+	}
+
+	return w.update(client, nil, nil)
+}
+
+// Poll counts the classes of all players once and updates the playables if a
+// class became full or available again. Nothing happens until the allowed
+// classes are known.
+func (w *CapWatcher) Poll() error {
+	w.mutex.Lock()
+	known := w.state.Allowed != nil
+	w.mutex.Unlock()
+	if !known {
+		return nil
+	}
+
+	players, err := w.client.GetPlayerData()
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[rcon.DinoClass]int)
+	for _, player := range players {
+		counts[player.DinoClass]++
+	}
+
+	var opened, closed []rcon.DinoClass
+
+	w.mutex.Lock()
+	for class, limit := range w.state.Caps {
+		full := counts[class] >= limit
+		if full && !w.full[class] {
+			closed = append(closed, class)
+		} else if !full && w.full[class] {
+			opened = append(opened, class)
+		}
+		w.full[class] = full
+	}
+	w.mutex.Unlock()
+
+	if len(opened) == 0 && len(closed) == 0 {
+		return nil
+	}
+
+	return w.update(w.client, opened, closed)
+}
+
+// update sends the current list of playables to the server and announces the
+// classes that were opened or closed.
+func (w *CapWatcher) update(client Client, opened, closed []rcon.DinoClass) error {
+	w.mutex.Lock()
+	allowed := w.state.Allowed
+	if allowed == nil {
+		w.mutex.Unlock()
+		return ErrAllowedUnknown
+	}
+	playables := make([]rcon.DinoClass, 0, len(allowed))
+	for _, class := range allowed {
+		if !w.full[class] {
+			playables = append(playables, class)
+		}
+	}
+	w.mutex.Unlock()
+
+	err := client.UpdatePlayables(playables)
+	if err != nil {
+		return err
+	}
+
+	slices.Sort(closed)
+	slices.Sort(opened)
+	isAllowed := func(class rcon.DinoClass) bool {
+		return slices.Contains(allowed, class)
+	}
+	closed = slices.DeleteFunc(closed, func(class rcon.DinoClass) bool { return !isAllowed(class) })
+	opened = slices.DeleteFunc(opened, func(class rcon.DinoClass) bool { return !isAllowed(class) })
+
+	for _, class := range closed {
+		message := fmt.Sprintf("%s has reached its player limit and cannot be picked right now.", class)
+		err = w.notify(client, message)
+		if err != nil {
+			return err
+		}
+	}
+	for _, class := range opened {
+		message := fmt.Sprintf("%s can be picked again.", class)
+		err = w.notify(client, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *CapWatcher) notify(client Client, message string) error {
+	w.log(message)
+	if w.config.Announce {
+		return client.Announce(message)
+	}
+	return nil
+}
+
+func (w *CapWatcher) save() error {
+	if w.config.DryRun {
+		return nil
+	}
+
+	w.mutex.Lock()
+	data, err := json.MarshalIndent(w.state, "", "  ")
+	w.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(w.path, data, 0644)
+}
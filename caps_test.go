@@ -0,0 +1,90 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func TestCapsNeedAllowedClasses(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	output := execute(t, repl, "caps set Carnotaurus 1")
+	if !strings.Contains(output, "classes allow") {
+		t.Errorf("expected a hint to set the allowed classes:\n%s", output)
+	}
+	if len(repl.capWatcher.Caps()) != 0 {
+		t.Errorf("got caps %v, want none", repl.capWatcher.Caps())
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if server.Playables != nil {
+		t.Errorf("playables should not be touched, got %v", server.Playables)
+	}
+}
+
+func TestCapWatcherPoll(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	playables := func() []rcon.DinoClass {
+		server.Lock()
+		defer server.Unlock()
+		return slices.Clone(server.Playables)
+	}
+
+	execute(t, repl, "classes allow Carnotaurus Stegosaurus Dryosaurus")
+
+	// Setting a cap polls right away, and Alice already plays a Carnotaurus
+	execute(t, repl, "caps set Carnotaurus 1")
+	repl.capWatcher.Stop()
+	want := []rcon.DinoClass{rcon.Stegosaurus, rcon.Dryosaurus}
+	if got := playables(); !slices.Equal(got, want) {
+		t.Errorf("got playables %v, want %v", got, want)
+	}
+	if !repl.capWatcher.IsFull(rcon.Carnotaurus) {
+		t.Error("Carnotaurus should be full")
+	}
+
+	server.RemovePlayer(alice.ID)
+	if err := repl.capWatcher.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	want = []rcon.DinoClass{rcon.Carnotaurus, rcon.Stegosaurus, rcon.Dryosaurus}
+	if got := playables(); !slices.Equal(got, want) {
+		t.Errorf("got playables %v, want %v", got, want)
+	}
+}
+
+func TestRemoveFullCap(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	execute(t, repl, "classes allow Carnotaurus Stegosaurus")
+	execute(t, repl, "caps set Stegosaurus 1")
+	repl.capWatcher.Stop()
+	execute(t, repl, "caps remove Stegosaurus")
+
+	server.Lock()
+	defer server.Unlock()
+	want := []rcon.DinoClass{rcon.Carnotaurus, rcon.Stegosaurus}
+	if !slices.Equal(server.Playables, want) {
+		t.Errorf("got playables %v, want %v", server.Playables, want)
+	}
+}
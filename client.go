@@ -0,0 +1,47 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import rcon "github.com/butt4cak3/theislercon"
+
+// Client contains the methods of rcon.Client that the commands use. Wrapping
+// it makes it possible to do things like logging every call.
+type Client interface {
+	GetPlayerList() ([]rcon.Player, error)
+	GetPlayerData() ([]rcon.Player, error)
+	GetServerDetails() (*rcon.ServerDetails, error)
+
+	Announce(message string) error
+	SendDirectMessage(playerID, message string) error
+	KickPlayer(playerID, reason string) error
+	WipeCorpses() error
+	UpdatePlayables(classes []rcon.DinoClass) error
+
+	ToggleWhitelist() (bool, error)
+	AddWhitelistID(playerID ...string) error
+	RemoveWhitelistID(playerID ...string) error
+
+	ToggleGlobalChat() (bool, error)
+	ToggleHumans() (bool, error)
+
+	ToggleAI() (bool, error)
+	DisableAIClasses(classes []rcon.AIClass) error
+	SetAIDensity(density float32) error
+
+	ExecCommand(command byte, params ...string) (string, error)
+}
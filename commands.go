@@ -18,22 +18,37 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	rcon "github.com/butt4cak3/theislercon"
 	"golang.org/x/text/message"
 )
 
-func statusCommand(client *rcon.Client) error {
+func statusCommand(client Client, args []string) error {
+	format, _, ok := parseFormatFlag(args)
+	if !ok {
+		return nil
+	}
+
 	details, err := client.GetServerDetails()
 	if err != nil {
 		return err
 	}
 
+	if format != nil {
+		printTemplate(format, details)
+		return nil
+	}
+
 	yesno := func(v bool) string {
 		if v {
 			return "yes"
@@ -54,32 +69,78 @@ func statusCommand(client *rcon.Client) error {
 	return nil
 }
 
-func announceCommand(client *rcon.Client, args []string) error {
+func announceCommand(client Client, messages MessagesConfig, args []string) error {
 	if len(args) < 1 {
 		fmt.Println("Missing MESSAGE")
 		return nil
 	}
 
-	message := strings.Join(args, " ")
-	return client.Announce(message)
+	args, isTemplate := messages.templateReference(args)
+	message := unescapeMessage(strings.Join(args, " "))
+	if isTemplate {
+		expanded, ok, err := messages.Expand(client, args)
+		if err != nil || !ok {
+			return err
+		}
+		message = expanded
+	}
+	return messages.Send(message, client.Announce)
 }
 
-func playerListCommand(client *rcon.Client) error {
+func playerListCommand(client Client, zones *ZoneMap, args []string) error {
+	format, _, ok := parseFormatFlag(args)
+	if !ok {
+		return nil
+	}
+
+	// The player list only contains IDs and names, templates can use
+	// everything
+	if format != nil {
+		players, err := client.GetPlayerData()
+		if err != nil {
+			return err
+		}
+		for _, player := range players {
+			printTemplate(format, player)
+		}
+		return nil
+	}
+
 	players, err := client.GetPlayerList()
 	if err != nil {
 		return err
 	}
 
+	// The player list doesn't contain locations. Players that are still
+	// choosing a class are missing from the player data, so they are shown
+	// without a zone.
+	var locations map[string]rcon.Location
+	if zones != nil {
+		data, err := client.GetPlayerData()
+		if err != nil {
+			return err
+		}
+		locations = make(map[string]rcon.Location, len(data))
+		for _, player := range data {
+			locations[player.ID] = player.Location
+		}
+	}
+
 	fmt.Println("Connected players:")
 
 	for _, player := range players {
-		fmt.Printf("    %s\n", player.Name)
+		location, ok := locations[player.ID]
+		if !ok {
+			fmt.Printf("    %s\n", player.Name)
+			continue
+		}
+		fmt.Printf("    %s %s\n", padRight(player.Name, 24), zones.Describe(location))
 	}
 
 	return nil
 }
 
-func messageCommand(client *rcon.Client, args []string) error {
+func messageCommand(client Client, messages MessagesConfig, args []string) error {
 	if len(args) < 1 {
 		fmt.Println("Missing PLAYER_NAME")
 		return nil
@@ -91,51 +152,111 @@ func messageCommand(client *rcon.Client, args []string) error {
 	playerID, err := ResolvePlayerName(client, args[0])
 	if err != nil {
 		if errors.Is(err, ErrPlayerNotFound) {
-			fmt.Printf("Player \"%s\" not found\n", args[0])
-			return nil
+			return playerNotFound(client, args[0])
+		}
+		if errors.Is(err, ErrAmbiguousName) {
+			return playerAmbiguous(client, args[0])
 		}
 		return err
 	}
-	message := strings.Join(args[1:], " ")
-	err = client.SendDirectMessage(playerID, message)
+	text, isTemplate := messages.templateReference(args[1:])
+	message := unescapeMessage(strings.Join(text, " "))
+	if isTemplate {
+		// The recipient fills {player} and {class}, no matter how they were
+		// typed
+		recipient, err := playerByID(client, playerID)
+		if err != nil {
+			return err
+		}
+		values := []string{"player=" + recipient.Name}
+		if recipient.DinoClass != "" {
+			values = append(values, "class="+string(recipient.DinoClass))
+		}
+		expanded, ok, err := messages.Expand(client, append(slices.Clone(text), values...))
+		if err != nil || !ok {
+			return err
+		}
+		message = expanded
+	}
+	return messages.Send(message, func(part string) error {
+		return client.SendDirectMessage(playerID, part)
+	})
+}
+
+// playerByID returns the player with an ID. Players that are still choosing
+// a class are only in the player list, so they have no class.
+func playerByID(client Client, playerID string) (rcon.Player, error) {
+	players, err := client.GetPlayerData()
 	if err != nil {
-		return err
+		return rcon.Player{}, err
+	}
+	if i := slices.IndexFunc(players, func(p rcon.Player) bool { return p.ID == playerID }); i >= 0 {
+		return players[i], nil
 	}
 
-	return nil
+	players, err = client.GetPlayerList()
+	if err != nil {
+		return rcon.Player{}, err
+	}
+	if i := slices.IndexFunc(players, func(p rcon.Player) bool { return p.ID == playerID }); i >= 0 {
+		return players[i], nil
+	}
+	return rcon.Player{}, ErrPlayerNotFound
 }
 
-func infoCommand(client *rcon.Client, args []string) error {
+func infoCommand(client Client, cache *NameCache, zones *ZoneMap, args []string) error {
+	format, args, ok := parseFormatFlag(args)
+	if !ok {
+		return nil
+	}
+
 	if len(args) < 1 {
 		fmt.Println("Missing PLAYER_NAME")
 		return nil
 	}
 
-	playerName := strings.ToLower(args[0])
-
 	players, err := client.GetPlayerData()
 	if err != nil {
 		return err
 	}
 
-	for _, player := range players {
-		if strings.ToLower(player.Name) == playerName {
-			p := message.NewPrinter(message.MatchLanguage("en"))
-			fmt.Printf("Player %s\n", player.Name)
-			fmt.Printf("    ID:       %s\n", player.ID)
-			fmt.Printf("    Class:    %s\n", player.DinoClass.Name())
-			fmt.Printf("    Growth:   %d%%, Health: %d%%, Stamina: %d%%, Hunger: %d%%, Thirst: %d%%\n", player.Growth, player.Health, player.Stamina, player.Hunger, player.Thirst)
-			p.Printf("    Location: %.3f, %.3f, %.3f\n", player.Location.Y, player.Location.X, player.Location.Z)
+	matches := matchingPlayers(players, args[0])
+	if len(matches) > 1 {
+		return playerAmbiguous(client, args[0])
+	}
+	if len(matches) == 1 {
+		player := matches[0]
+		if format != nil {
+			printTemplate(format, player)
 			return nil
 		}
+		p := message.NewPrinter(message.MatchLanguage("en"))
+		fmt.Printf("Player %s\n", player.Name)
+		fmt.Printf("    ID:       %s\n", player.ID)
+		fmt.Printf("    Class:    %s\n", player.DinoClass.Name())
+		fmt.Printf("    Growth:   %d%%, Health: %d%%, Stamina: %d%%, Hunger: %d%%, Thirst: %d%%\n", player.Growth, player.Health, player.Stamina, player.Hunger, player.Thirst)
+		p.Printf("    Location: %.3f, %.3f, %.3f\n", player.Location.Y, player.Location.X, player.Location.Z)
+		if zone := zones.Describe(player.Location); zone != "" {
+			fmt.Printf("    Zone:     %s\n", zone)
+		}
+		return nil
+	}
+
+	if entries := cache.Lookup(args[0]); len(entries) > 0 {
+		fmt.Printf("Player %s is not online. From the name cache:\n", entries[0].Name)
+		for _, entry := range entries {
+			fmt.Printf("    ID: %s, last seen %s (%s ago)\n", entry.ID, entry.LastSeen.Format(time.DateTime), formatDuration(time.Since(entry.LastSeen)))
+		}
+		return nil
 	}
 
 	fmt.Printf("Player \"%s\" not found\n", args[0])
+	printSuggestions(players, args[0])
 
 	return nil
 }
 
-func classesCommand(client *rcon.Client, args []string) error {
+func classesCommand(client Client, caps *CapWatcher, confirm *Confirmer, args []string) error {
 	if len(args) == 0 {
 		fmt.Println("No subcommand provided")
 		fmt.Println("Type \"help classes\" to learn more about this command.")
@@ -161,16 +282,21 @@ func classesCommand(client *rcon.Client, args []string) error {
 		if len(args) == 1 && args[0] == "all" {
 			classes = rcon.AllClasses[:]
 		} else {
-			classes = make([]rcon.DinoClass, len(args))
-			for i, arg := range args {
-				if !rcon.IsClass(arg) {
-					fmt.Printf("\"%s\" is not a class. Type \"classes list\" to get a list of all classes.", arg)
-					return nil
-				}
-				classes[i] = rcon.DinoClass(arg)
+			var ok bool
+			classes, ok = parseClasses(args)
+			if !ok {
+				return nil
 			}
 		}
-		return client.UpdatePlayables(classes)
+		current := "unknown"
+		if allowed := caps.Allowed(); allowed != nil {
+			current = joinClasses(allowed)
+		}
+		description := fmt.Sprintf("Only these classes will be allowed: %s\nAs far as PteroPrompt knows, these classes are allowed right now: %s", joinClasses(classes), current)
+		if !confirm.Confirm("classes allow", description) {
+			return nil
+		}
+		return caps.SetAllowed(client, classes)
 	default:
 		fmt.Printf("Invalid subcommand \"%s\".\n", cmd)
 		fmt.Println("Type \"help classes\" to learn more about this command.")
@@ -178,7 +304,109 @@ func classesCommand(client *rcon.Client, args []string) error {
 	}
 }
 
-func whitelistCommand(client *rcon.Client, args []string) error {
+func joinClasses(classes []rcon.DinoClass) string {
+	names := make([]string, len(classes))
+	for i, class := range classes {
+		names[i] = class.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseClasses turns a list of class names into classes. If one of the names
+// is not a class, an error message is printed and ok is false.
+func parseClasses(names []string) (classes []rcon.DinoClass, ok bool) {
+	classes = make([]rcon.DinoClass, len(names))
+	for i, name := range names {
+		if !rcon.IsClass(name) {
+			fmt.Printf("\"%s\" is not a class. Type \"classes list\" to get a list of all classes.\n", name)
+			return nil, false
+		}
+		classes[i] = rcon.DinoClass(name)
+	}
+	return classes, true
+}
+
+func capsCommand(client Client, caps *CapWatcher, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("No subcommand provided.")
+		fmt.Println("Type \"help caps\" to learn more about this command.")
+		return nil
+	}
+
+	cmd, args := strings.ToLower(args[0]), args[1:]
+
+	switch cmd {
+	case "list":
+		limits := caps.Caps()
+		if len(limits) == 0 {
+			fmt.Println("No class caps set.")
+			return nil
+		}
+		players, err := client.GetPlayerData()
+		if err != nil {
+			return err
+		}
+		counts := make(map[rcon.DinoClass]int)
+		for _, player := range players {
+			counts[player.DinoClass]++
+		}
+		fmt.Println("Class caps:")
+		for _, class := range slices.Sorted(maps.Keys(limits)) {
+			status := ""
+			if caps.IsFull(class) {
+				status = " (full)"
+			}
+			fmt.Printf("    %-20s %d/%d%s\n", class, counts[class], limits[class], status)
+		}
+		return nil
+	case "set":
+		if len(args) < 2 {
+			fmt.Println("Usage: caps set CLASS LIMIT")
+			return nil
+		}
+		classes, ok := parseClasses(args[:1])
+		if !ok {
+			return nil
+		}
+		limit, err := strconv.Atoi(args[1])
+		if err != nil || limit < 0 {
+			fmt.Println("The limit must be zero or a positive number.")
+			return nil
+		}
+		err = caps.SetCap(classes[0], limit)
+		if errors.Is(err, ErrAllowedUnknown) {
+			fmt.Println("PteroPrompt doesn't know which classes are allowed on the server, so it can't enforce caps yet.")
+			fmt.Println("Set them with \"classes allow\" first, for example \"classes allow all\".")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s is now limited to %d players\n", classes[0], limit)
+		return nil
+	case "remove":
+		if len(args) < 1 {
+			fmt.Println("Usage: caps remove CLASS")
+			return nil
+		}
+		classes, ok := parseClasses(args[:1])
+		if !ok {
+			return nil
+		}
+		err := caps.RemoveCap(client, classes[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s is no longer limited\n", classes[0])
+		return nil
+	default:
+		fmt.Printf("Invalid subcommand \"%s\".\n", cmd)
+		fmt.Println("Type \"help caps\" to learn more about this command.")
+		return nil
+	}
+}
+
+func whitelistCommand(client Client, cache *NameCache, confirm *Confirmer, args []string) error {
 	if len(args) == 0 {
 		fmt.Println("No subcommand provided.")
 		fmt.Println("Type \"help whitelist\" to learn more about this command.")
@@ -189,6 +417,17 @@ func whitelistCommand(client *rcon.Client, args []string) error {
 
 	switch cmd {
 	case "toggle":
+		details, err := client.GetServerDetails()
+		if err != nil {
+			return err
+		}
+		description := "The whitelist is currently off and will be turned on."
+		if details.Whitelist {
+			description = "The whitelist is currently on and will be turned off."
+		}
+		if !confirm.Confirm("whitelist toggle", description) {
+			return nil
+		}
 		status, err := client.ToggleWhitelist()
 		if err != nil {
 			return err
@@ -215,19 +454,11 @@ func whitelistCommand(client *rcon.Client, args []string) error {
 			fmt.Println("No PlayerIDs provided.")
 			return nil
 		}
-		playerIDs := make([]string, len(args))
-		for i, name := range args {
-			id, err := ResolvePlayerName(client, name)
-			if err != nil {
-				if err == ErrPlayerNotFound {
-					playerIDs[i] = name
-					continue
-				}
-				return err
-			}
-			playerIDs[i] = id
+		playerIDs, ok, err := resolveWhitelistIDs(client, cache, args)
+		if err != nil || !ok {
+			return err
 		}
-		err := client.AddWhitelistID(playerIDs...)
+		err = client.AddWhitelistID(playerIDs...)
 		if err != nil {
 			return err
 		}
@@ -238,19 +469,11 @@ func whitelistCommand(client *rcon.Client, args []string) error {
 			fmt.Println("No PlayerIDs provided.")
 			return nil
 		}
-		playerIDs := make([]string, len(args))
-		for i, name := range args {
-			id, err := ResolvePlayerName(client, name)
-			if err != nil {
-				if err == ErrPlayerNotFound {
-					playerIDs[i] = name
-					continue
-				}
-				return err
-			}
-			playerIDs[i] = id
+		playerIDs, ok, err := resolveWhitelistIDs(client, cache, args)
+		if err != nil || !ok {
+			return err
 		}
-		err := client.RemoveWhitelistID(playerIDs...)
+		err = client.RemoveWhitelistID(playerIDs...)
 		if err != nil {
 			return err
 		}
@@ -263,7 +486,35 @@ func whitelistCommand(client *rcon.Client, args []string) error {
 	}
 }
 
-func kickCommand(client *rcon.Client, args []string) error {
+// resolveWhitelistIDs turns the names in args into IDs. Names of players that
+// are offline are looked up in the cache, everything else is expected to be
+// an ID already. ok is false if a name is ambiguous, which has already been
+// reported to the user.
+func resolveWhitelistIDs(client Client, cache *NameCache, args []string) (playerIDs []string, ok bool, err error) {
+	playerIDs = make([]string, len(args))
+	for i, name := range args {
+		id, cached, err := resolvePlayer(client, cache, name)
+		switch {
+		case errors.Is(err, ErrPlayerNotFound):
+			playerIDs[i] = name
+		case errors.Is(err, ErrAmbiguousCachedName):
+			printAmbiguous(cache, name)
+			return nil, false, nil
+		case errors.Is(err, ErrAmbiguousName):
+			return nil, false, playerAmbiguous(client, name)
+		case err != nil:
+			return nil, false, err
+		default:
+			if cached != nil {
+				printCached(cached)
+			}
+			playerIDs[i] = id
+		}
+	}
+	return playerIDs, true, nil
+}
+
+func kickCommand(client Client, args []string) error {
 	if len(args) == 0 {
 		fmt.Println("Missing player name")
 		return nil
@@ -279,8 +530,10 @@ func kickCommand(client *rcon.Client, args []string) error {
 	playerID, err := ResolvePlayerName(client, playerName)
 	if err != nil {
 		if err == ErrPlayerNotFound {
-			fmt.Printf("No such player \"%s\"\n", playerName)
-			return nil
+			return playerNotFound(client, playerName)
+		}
+		if err == ErrAmbiguousName {
+			return playerAmbiguous(client, playerName)
 		}
 		return err
 	}
@@ -292,7 +545,76 @@ func kickCommand(client *rcon.Client, args []string) error {
 	return nil
 }
 
-func wipeCorpsesCommand(client *rcon.Client) error {
+func banCommand(client Client, cache *NameCache, confirm *Confirmer, args []string) error {
+	minutes := 0
+	if i := slices.Index(args, "--minutes"); i >= 0 {
+		if i+1 >= len(args) {
+			fmt.Println("Missing value for --minutes")
+			return nil
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid number of minutes \"%s\"\n", args[i+1])
+			return nil
+		}
+		minutes = n
+		args = slices.Delete(slices.Clone(args), i, i+2)
+	}
+
+	if len(args) == 0 {
+		fmt.Println("Missing PLAYER_NAME")
+		return nil
+	}
+
+	playerName := args[0]
+	reason := "You were banned from the server."
+	if len(args) > 1 {
+		reason = strings.Join(args[1:], " ")
+	}
+
+	playerID, cached, err := resolvePlayer(client, cache, playerName)
+	switch {
+	case errors.Is(err, ErrPlayerNotFound) && isPlayerID(playerName):
+		playerID = playerName
+	case errors.Is(err, ErrPlayerNotFound):
+		return playerNotFound(client, playerName)
+	case errors.Is(err, ErrAmbiguousCachedName):
+		printAmbiguous(cache, playerName)
+		return nil
+	case errors.Is(err, ErrAmbiguousName):
+		return playerAmbiguous(client, playerName)
+	case err != nil:
+		return err
+	case cached != nil:
+		printCached(cached)
+		playerName = cached.Name
+	}
+
+	duration := "permanently"
+	if minutes > 0 {
+		duration = "for " + formatDuration(time.Duration(minutes)*time.Minute)
+	}
+	if !confirm.Confirm("ban", fmt.Sprintf("%s (%s) will be banned %s.", playerName, playerID, duration)) {
+		return nil
+	}
+
+	// The server expects the name, the ID, the reason and the duration in
+	// minutes, where 0 means forever. The parameters are separated by
+	// commas, so there must not be any in the name or the reason.
+	playerName = strings.ReplaceAll(playerName, ",", "")
+	reason = strings.ReplaceAll(reason, ",", "")
+	_, err = client.ExecCommand(byte(rcon.BanPlayer), playerName, playerID, reason, strconv.Itoa(minutes))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s was banned %s. Reason: %s\n", playerName, duration, reason)
+	return nil
+}
+
+func wipeCorpsesCommand(client Client, confirm *Confirmer) error {
+	if !confirm.Confirm("wipe_corpses", "All corpses on the map will be removed.") {
+		return nil
+	}
 	err := client.WipeCorpses()
 	if err != nil {
 		return err
@@ -301,7 +623,7 @@ func wipeCorpsesCommand(client *rcon.Client) error {
 	return nil
 }
 
-func toggleGlobalChatCommand(client *rcon.Client) error {
+func toggleGlobalChatCommand(client Client) error {
 	state, err := client.ToggleGlobalChat()
 	if err != nil {
 		return err
@@ -314,7 +636,7 @@ func toggleGlobalChatCommand(client *rcon.Client) error {
 	return err
 }
 
-func toggleHumansCommand(client *rcon.Client) error {
+func toggleHumansCommand(client Client) error {
 	status, err := client.ToggleHumans()
 	if err != nil {
 		return err
@@ -327,7 +649,7 @@ func toggleHumansCommand(client *rcon.Client) error {
 	return err
 }
 
-func aiCommand(client *rcon.Client, args []string) error {
+func aiCommand(client Client, confirm *Confirmer, args []string) error {
 	if len(args) == 0 {
 		fmt.Println("No subcommand provided.")
 		fmt.Println("Type \"help whitelist\" to learn more about this command.")
@@ -375,7 +697,29 @@ func aiCommand(client *rcon.Client, args []string) error {
 				classes[i] = rcon.AIClass(arg)
 			}
 		}
-		err := client.DisableAIClasses(classes)
+		details, err := client.GetServerDetails()
+		if err != nil {
+			return err
+		}
+		var description string
+		if details.SpawnAI {
+			description = "AI spawning is currently on.\n"
+		} else {
+			description = "AI spawning is currently off.\n"
+		}
+		if len(classes) == 0 {
+			description += "All AI classes will be enabled."
+		} else {
+			names := make([]string, len(classes))
+			for i, class := range classes {
+				names[i] = string(class)
+			}
+			description += "These AI classes will be disabled: " + strings.Join(names, ", ")
+		}
+		if !confirm.Confirm("ai disable", description) {
+			return nil
+		}
+		err = client.DisableAIClasses(classes)
 		if err != nil {
 			return err
 		}
@@ -404,19 +748,57 @@ func aiCommand(client *rcon.Client, args []string) error {
 	}
 }
 
-func customCommand(client *rcon.Client, args []string) error {
+func customCommand(client Client, confirm *Confirmer, args []string) error {
+	hexdump := false
+	timeout := defaultSendTimeout
+
+	// Options have to come before the code, so that arguments can start with
+	// dashes
+Options:
+	for len(args) > 0 {
+		switch args[0] {
+		case "--hex":
+			hexdump = true
+			args = args[1:]
+		case "--timeout":
+			if len(args) < 2 {
+				fmt.Println("Missing DURATION")
+				return nil
+			}
+			d, err := time.ParseDuration(args[1])
+			if err != nil || d <= 0 || d > defaultSendTimeout {
+				fmt.Printf("Timeout must be a duration between 0 and %s, e.g. 500ms\n", defaultSendTimeout)
+				return nil
+			}
+			timeout = d
+			args = args[2:]
+		default:
+			break Options
+		}
+	}
+
 	if len(args) < 1 {
 		fmt.Println("Missing command byte")
 		return nil
 	}
 
-	commandByte, err := strconv.ParseUint(args[0], 16, 8)
-	if err != nil {
-		fmt.Println("Command byte must be a hexadecimal number, e.g. 3a")
+	commandByte, ok := ParseOpcode(args[0])
+	if !ok {
+		fmt.Println("Command must be a hexadecimal number, e.g. 3a, or the name of an opcode.")
+		fmt.Println("Type \"opcodes\" to get a list of all known opcodes.")
 		return nil
 	}
 
-	response, err := client.ExecCommand(byte(commandByte), args[1:]...)
+	command := fmt.Sprintf("%02x", commandByte)
+	if name := OpcodeName(commandByte); name != "" {
+		command += " (" + name + ")"
+	}
+	description := fmt.Sprintf("The raw command %s will be sent to the server with the arguments \"%s\".", command, strings.Join(args[1:], ","))
+	if !confirm.Confirm("send", description) {
+		return nil
+	}
+
+	response, err := execWithTimeout(client, timeout, commandByte, args[1:]...)
 	if err != nil {
 		if ne, ok := err.(net.Error); ok && ne.Timeout() {
 			fmt.Println("The server did not respond with anything.")
@@ -425,6 +807,450 @@ func customCommand(client *rcon.Client, args []string) error {
 		return err
 	}
 
-	fmt.Println(response)
+	if hexdump {
+		fmt.Print(hex.Dump([]byte(response)))
+	} else {
+		fmt.Println(response)
+	}
+	return nil
+}
+
+// defaultSendTimeout is how long the client library waits for a response. It
+// can't be changed, so shorter timeouts are the only option.
+const defaultSendTimeout = 5 * time.Second
+
+// execWithTimeout stops waiting for the response to a command after timeout.
+// The command is still running in the background after that, so the next
+// command has to wait until the client library gives up as well.
+func execWithTimeout(client Client, timeout time.Duration, command byte, params ...string) (string, error) {
+	type result struct {
+		response string
+		err      error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		response, err := client.ExecCommand(command, params...)
+		done <- result{response, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-time.After(timeout):
+		return "", os.ErrDeadlineExceeded
+	}
+}
+
+func opcodesCommand() error {
+	fmt.Println("Known opcodes:")
+	for _, op := range opcodes {
+		fmt.Printf("    %02x  %-17s %-18s %s\n", op.Code, op.Name, op.Params, op.Description)
+	}
+	fmt.Println()
+	fmt.Println("Parameters are separated by commas. The server doesn't respond to opcodes that it doesn't know.")
+	return nil
+}
+
+func probeCommand(client Client, reconnect func(), confirm *Confirmer, dataPath func(name string) (string, error), args []string) error {
+	var params []string
+	if len(args) >= 2 && args[0] == "--params" {
+		params = strings.Split(args[1], ",")
+		args = args[2:]
+	}
+
+	if len(args) < 2 {
+		fmt.Println("Missing FROM or TO")
+		fmt.Println("Type \"help probe\" to learn more about this command.")
+		return nil
+	}
+
+	from, err := strconv.ParseUint(args[0], 16, 8)
+	if err != nil {
+		fmt.Println("FROM must be a hexadecimal number, e.g. a0")
+		return nil
+	}
+	to, err := strconv.ParseUint(args[1], 16, 8)
+	if err != nil || to < from {
+		fmt.Println("TO must be a hexadecimal number that is not smaller than FROM, e.g. af")
+		return nil
+	}
+
+	started := time.Now()
+	var reportPath string
+	if len(args) > 2 {
+		reportPath = args[2]
+	} else {
+		reportPath, err = dataPath("probe-" + started.Format("20060102-150405") + ".txt")
+		if err != nil {
+			return err
+		}
+	}
+
+	skipped := 0
+	for code := from; code <= to; code++ {
+		if probeDenyList[byte(code)] {
+			skipped++
+		}
+	}
+	probed := int(to-from) + 1 - skipped
+	description := fmt.Sprintf(
+		"%d opcodes from %02x to %02x will be sent to the server with the parameters %q. %d known opcodes that change things on the server are skipped.\n"+
+			"Unknown opcodes may do anything, including things that you can't undo. This takes up to %s.",
+		probed, from, to, params, skipped, time.Duration(probed)*defaultSendTimeout,
+	)
+	// Probing can't be undone, so it is never confirmed automatically
+	if !confirm.ConfirmAlways(description) {
+		return nil
+	}
+
+	results := Probe(client, reconnect, byte(from), byte(to), params, func(r ProbeResult) {
+		fmt.Println(r)
+	})
+
+	f, err := os.Create(reportPath)
+	if err != nil {
+		fmt.Printf("Cannot write report: %v\n", err)
+		return nil
+	}
+	defer f.Close()
+
+	if err := WriteProbeReport(f, results, params, started); err != nil {
+		fmt.Printf("Cannot write report: %v\n", err)
+		return nil
+	}
+	fmt.Printf("Report written to %s\n", reportPath)
+	return nil
+}
+
+func watchCommand(watcher *PlayerWatcher, printer *EventPrinter, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("No subcommand provided.")
+		fmt.Println("Type \"help watch\" to learn more about this command.")
+		return nil
+	}
+
+	cmd, args := strings.ToLower(args[0]), args[1:]
+
+	switch cmd {
+	case "players":
+		enable := true
+		if len(args) > 0 {
+			switch strings.ToLower(args[0]) {
+			case "on":
+			case "off":
+				enable = false
+			default:
+				fmt.Printf("Invalid argument \"%s\". Expected \"on\" or \"off\".\n", args[0])
+				return nil
+			}
+		}
+		printer.enabled.Store(enable)
+		if enable {
+			watcher.Start()
+			fmt.Println("Showing players joining and leaving the server")
+		} else {
+			fmt.Println("No longer showing players joining and leaving the server")
+		}
+		return nil
+	default:
+		fmt.Printf("Invalid subcommand \"%s\".\n", cmd)
+		fmt.Println("Type \"help watch\" to learn more about this command.")
+		return nil
+	}
+}
+
+func rulesCommand(client Client, engine *RuleEngine, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("No subcommand provided.")
+		fmt.Println("Type \"help rules\" to learn more about this command.")
+		return nil
+	}
+
+	cmd := strings.ToLower(args[0])
+
+	switch cmd {
+	case "list":
+		rules := engine.Rules()
+		if len(rules) == 0 {
+			fmt.Println("No rules configured.")
+			return nil
+		}
+		fmt.Println("Rules:")
+		for _, rule := range rules {
+			fmt.Printf("    %s: %s, %d warnings before kick\n", rule.Name, rule.Describe(), rule.Warnings)
+		}
+		return nil
+	case "check":
+		players, err := client.GetPlayerData()
+		if err != nil {
+			return err
+		}
+		violations := engine.Check(players, time.Now())
+		if len(violations) == 0 {
+			fmt.Println("Nobody is breaking any rules right now.")
+			return nil
+		}
+		fmt.Println("Rule violations:")
+		for _, v := range violations {
+			fmt.Printf("    %s (%s) breaks \"%s\"\n", v.Player.Name, v.Player.ID, v.Rule.Name)
+		}
+		return nil
+	case "start":
+		engine.Start()
+		if engine.config.DryRun {
+			fmt.Println("Rule enforcement is now on (dry run)")
+		} else {
+			fmt.Println("Rule enforcement is now on")
+		}
+		return nil
+	case "stop":
+		engine.Stop()
+		fmt.Println("Rule enforcement is now off")
+		return nil
+	case "status":
+		if !engine.Running() {
+			fmt.Println("Rule enforcement is currently off")
+		} else if engine.config.DryRun {
+			fmt.Println("Rule enforcement is currently on (dry run)")
+		} else {
+			fmt.Println("Rule enforcement is currently on")
+		}
+		return nil
+	default:
+		fmt.Printf("Invalid subcommand \"%s\".\n", cmd)
+		fmt.Println("Type \"help rules\" to learn more about this command.")
+		return nil
+	}
+}
+
+func seenCommand(store *SessionStore, args []string) error {
+	if store == nil {
+		printSessionsDisabled()
+		return nil
+	}
+	if len(args) < 1 {
+		fmt.Println("Missing PLAYER_NAME or PLAYER_ID")
+		return nil
+	}
+
+	record, err := store.FindPlayer(args[0])
+	if err != nil {
+		if errors.Is(err, ErrUnknownPlayer) {
+			fmt.Printf("\"%s\" has never been seen on the server\n", args[0])
+			return nil
+		}
+		return err
+	}
+
+	if record.Online {
+		fmt.Printf("%s (%s) is online right now\n", record.Names[0], record.ID)
+	} else {
+		fmt.Printf("%s (%s) was last seen %s (%s ago)\n", record.Names[0], record.ID, record.LastSeen.Format(time.DateTime), formatDuration(time.Since(record.LastSeen)))
+	}
+	if len(record.Names) > 1 {
+		fmt.Printf("    Also known as: %s\n", strings.Join(record.Names[1:], ", "))
+	}
+
+	sessions, err := store.Sessions(record.ID, 5)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("    Recent sessions:")
+	for _, session := range sessions {
+		end := session.End
+		until := end.Format(time.TimeOnly)
+		if end.IsZero() {
+			end = time.Now()
+			until = "now"
+		}
+		fmt.Printf("        %s - %-8s %10s  %s, %d%% growth\n", session.Start.Format(time.DateTime), until, formatDuration(end.Sub(session.Start)), session.Class, session.PeakGrowth)
+	}
+
+	return nil
+}
+
+func playtimeCommand(store *SessionStore, args []string) error {
+	if store == nil {
+		printSessionsDisabled()
+		return nil
+	}
+
+	since, args, ok := parseSinceFlag(args)
+	if !ok {
+		return nil
+	}
+	if len(args) < 1 {
+		fmt.Println("Missing PLAYER_ID")
+		return nil
+	}
+
+	record, err := store.FindPlayer(args[0])
+	if err != nil {
+		if errors.Is(err, ErrUnknownPlayer) {
+			fmt.Printf("\"%s\" has never been seen on the server\n", args[0])
+			return nil
+		}
+		return err
+	}
+
+	playtime, err := store.Playtime(record.ID, since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s) played for %s in %d sessions\n", record.Names[0], record.ID, formatDuration(playtime.Duration), playtime.Sessions)
+	return nil
+}
+
+func topCommand(store *SessionStore, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("No subcommand provided.")
+		fmt.Println("Type \"help top\" to learn more about this command.")
+		return nil
+	}
+
+	cmd, args := strings.ToLower(args[0]), args[1:]
+
+	switch cmd {
+	case "playtime":
+		if store == nil {
+			printSessionsDisabled()
+			return nil
+		}
+		since, _, ok := parseSinceFlag(args)
+		if !ok {
+			return nil
+		}
+		top, err := store.TopPlaytime(since, time.Now(), 10)
+		if err != nil {
+			return err
+		}
+		if len(top) == 0 {
+			fmt.Println("Nobody played in that time.")
+			return nil
+		}
+		fmt.Println("Players with the most playtime:")
+		for i, p := range top {
+			fmt.Printf("    %2d. %s %-20s %10s in %d sessions\n", i+1, padRight(p.Name, 24), p.PlayerID, formatDuration(p.Duration), p.Sessions)
+		}
+		return nil
+	default:
+		fmt.Printf("Invalid subcommand \"%s\".\n", cmd)
+		fmt.Println("Type \"help top\" to learn more about this command.")
+		return nil
+	}
+}
+
+func printSessionsDisabled() {
+	fmt.Println("Session tracking is turned off.")
+	fmt.Println("Set \"sessions\": { \"enabled\": true } in the config file to record who plays on the server.")
+}
+
+// parseSinceFlag removes "--since DURATION" from args. Durations can be given
+// in days, e.g. 7d, or anything time.ParseDuration understands. Without the
+// flag, since is the zero time.
+func parseSinceFlag(args []string) (since time.Time, rest []string, ok bool) {
+	for i, arg := range args {
+		if arg != "--since" {
+			continue
+		}
+		if i+1 >= len(args) {
+			fmt.Println("Missing duration after --since, e.g. --since 7d")
+			return time.Time{}, nil, false
+		}
+		d, err := parseDuration(args[i+1])
+		if err != nil {
+			fmt.Printf("Invalid duration \"%s\". Try something like 7d or 12h.\n", args[i+1])
+			return time.Time{}, nil, false
+		}
+		rest = append(rest, args[:i]...)
+		rest = append(rest, args[i+2:]...)
+		return time.Now().Add(-d), rest, true
+	}
+	return time.Time{}, args, true
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * 24 * float64(time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+func auditCommand(log *AuditLog, args []string) error {
+	var filter AuditFilter
+	limit := 20
+
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			fmt.Printf("Missing value after %s\n", args[i])
+			return nil
+		}
+		value := args[i+1]
+		switch strings.ToLower(args[i]) {
+		case "--since":
+			d, err := parseDuration(value)
+			if err != nil {
+				fmt.Printf("Invalid duration \"%s\". Try something like 7d or 12h.\n", value)
+				return nil
+			}
+			filter.Since = time.Now().Add(-d)
+		case "--operator":
+			filter.Operator = value
+		case "--action":
+			filter.Action = value
+		case "--player":
+			filter.Player = value
+		case "--limit":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				fmt.Println("The limit must be a positive number.")
+				return nil
+			}
+			limit = n
+		default:
+			fmt.Printf("Invalid option \"%s\".\n", args[i])
+			fmt.Println("Type \"help audit\" to learn more about this command.")
+			return nil
+		}
+		i++
+	}
+
+	entries, err := log.Entries(filter.Match)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No matching entries in the audit log.")
+		return nil
+	}
+
+	entries = entries[max(0, len(entries)-limit):]
+	for _, entry := range entries {
+		fmt.Printf("%s  %-12s %-18s %-40s %s\n", entry.Time.Local().Format(time.DateTime), entry.Operator, entry.Action, strings.Join(entry.Args, ", "), entry.Result)
+		fmt.Printf("    on %s by \"%s\"\n", entry.Server, entry.Command)
+	}
+
 	return nil
 }
@@ -0,0 +1,175 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+)
+
+// Config is read from a JSON file when the program starts. Every field is
+// optional.
+type Config struct {
+	// DataDir is where files like the greeter state are stored. Defaults to
+	// the directory of the config file.
+	DataDir string `json:"data_dir"`
+
+	// Permission is read-only, moderator or admin. Defaults to admin.
+	Permission string `json:"permission"`
+
+	// Profiles can be selected on the command line and override the
+	// permission and the operator name.
+	Profiles map[string]Profile `json:"profiles"`
+
+	// Confirm turns confirmation prompts for individual actions on or off.
+	Confirm map[string]bool `json:"confirm"`
+
+	Greeter  GreeterConfig  `json:"greeter"`
+	Rules    RulesConfig    `json:"rules"`
+	Caps     CapsConfig     `json:"caps"`
+	Sessions SessionsConfig `json:"sessions"`
+	Audit    AuditConfig    `json:"audit"`
+
+	// Webhooks receive events like players joining or being kicked.
+	Webhooks []WebhookConfig `json:"webhooks"`
+
+	Messages MessagesConfig `json:"messages"`
+
+	// Bridge lets other programs send messages to the server.
+	Bridge BridgeConfig `json:"bridge"`
+
+	// ZonesFile is a file that names the areas of the map, see ZoneMap.
+	// Relative paths are relative to the config file.
+	ZonesFile string `json:"zones_file"`
+
+	// Aliases and Macros are commands that the user defined in the prompt.
+	Aliases map[string]string   `json:"aliases"`
+	Macros  map[string][]string `json:"macros"`
+
+	path string
+}
+
+// Profile is a set of connection details and permissions.
+type Profile struct {
+	Address    string `json:"address"`
+	Password   string `json:"password"`
+	Permission string `json:"permission"`
+	Operator   string `json:"operator"`
+}
+
+// DefaultConfigPath returns the path of the config file that is used if none
+// is passed on the command line.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "pteroprompt.json"
+	}
+	return filepath.Join(dir, "pteroprompt", "config.json")
+}
+
+// LoadConfig reads the config file at path. If the file does not exist and
+// mustExist is false, the default config is returned instead.
+func LoadConfig(path string, mustExist bool) (*Config, error) {
+	config := &Config{
+		path:    path,
+		DataDir: filepath.Dir(path),
+		Greeter: GreeterConfig{
+			WelcomeMessage:     "Welcome to {server}, {name}! There are currently {players} players online.",
+			WelcomeBackMessage: "Welcome back, {name}!",
+			IntervalHours:      12,
+		},
+		Caps: CapsConfig{
+			Announce: true,
+		},
+		Messages: MessagesConfig{
+			DelaySeconds: 3,
+			Templates:    maps.Clone(defaultTemplates),
+		},
+		Bridge: BridgeConfig{
+			MessagesPerMinute: 10,
+		},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !mustExist && errors.Is(err, fs.ErrNotExist) {
+			return config, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// DataPath returns the path of a file in the data directory and makes sure
+// that the directory exists.
+func (c *Config) DataPath(name string) (string, error) {
+	err := os.MkdirAll(c.DataDir, 0755)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.DataDir, name), nil
+}
+
+// RelativePath turns a path from the config into one that is relative to the
+// config file instead of the working directory.
+func (c *Config) RelativePath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.path), path)
+}
+
+// Update changes a single top-level key in the config file and leaves
+// everything else as it is. The file is created if it doesn't exist.
+func (c *Config) Update(key string, value any) error {
+	fields := make(map[string]json.RawMessage)
+
+	data, err := os.ReadFile(c.path)
+	if err == nil {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", c.path, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	fields[key], err = json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	data, err = json.MarshalIndent(fields, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, append(data, '\n'), 0644)
+}
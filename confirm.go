@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+// defaultConfirmations lists the actions that ask for confirmation unless the
+// config says otherwise.
+var defaultConfirmations = map[string]bool{
+	"wipe_corpses":     true,
+	"ban":              true,
+	"classes allow":    true,
+	"ai disable":       true,
+	"whitelist toggle": true,
+	"send":             true,
+}
+
+// Confirmer asks the user whether they really want to do something before a
+// command changes things on the server.
+type Confirmer struct {
+	rl          *readline.Instance
+	enabled     map[string]bool
+	yes         bool
+	interactive bool
+}
+
+// NewConfirmer creates a confirmer that reads the answers from rl. enabled
+// overrides the default for individual actions. If yes is true, every action
+// is confirmed without asking.
+func NewConfirmer(rl *readline.Instance, enabled map[string]bool, yes bool) *Confirmer {
+	c := &Confirmer{
+		rl:          rl,
+		enabled:     make(map[string]bool),
+		yes:         yes,
+		interactive: rl != nil && readline.IsTerminal(int(os.Stdin.Fd())),
+	}
+	for action, enable := range defaultConfirmations {
+		c.enabled[action] = enable
+	}
+	for action, enable := range enabled {
+		c.enabled[action] = enable
+	}
+	return c
+}
+
+// WithYes returns a confirmer that doesn't ask if yes is true.
+func (c *Confirmer) WithYes(yes bool) *Confirmer {
+	if !yes {
+		return c
+	}
+	confirmer := *c
+	confirmer.yes = true
+	return &confirmer
+}
+
+// Confirm prints what is going to happen and asks the user to confirm it. It
+// returns true right away if confirmations are turned off for the action.
+func (c *Confirmer) Confirm(action, description string) bool {
+	if c.yes || !c.enabled[action] {
+		return true
+	}
+	return c.ask(description)
+}
+
+// ConfirmAlways is like Confirm, but it asks even if the user said yes to
+// everything or turned confirmations off. It is meant for actions that
+// nobody should run without reading the description first. Without a
+// terminal, nobody can answer, so it refuses.
+func (c *Confirmer) ConfirmAlways(description string) bool {
+	if !c.interactive {
+		fmt.Println("This command has to be confirmed in a terminal.")
+		return false
+	}
+	return c.ask(description)
+}
+
+func (c *Confirmer) ask(description string) bool {
+	fmt.Println(description)
+
+	prompt := c.rl.Config.Prompt
+	c.rl.SetPrompt("Continue? [y/N] ")
+	defer c.rl.SetPrompt(prompt)
+
+	answer, err := c.rl.Readline()
+	if err != nil {
+		fmt.Println("Cancelled.")
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		fmt.Println("Cancelled.")
+		return false
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/chzyer/readline"
+)
+
+// newTestConfirmer returns a confirmer that reads the answers from input.
+func newTestConfirmer(t *testing.T, input string, enabled map[string]bool) *Confirmer {
+	t.Helper()
+
+	rl, err := readline.NewEx(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader(input)),
+		Stdout: io.Discard,
+		Stderr: io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rl.Close() })
+	return NewConfirmer(rl, enabled, false)
+}
+
+func TestConfirm(t *testing.T) {
+	confirmer := newTestConfirmer(t, "n\nyes\n", map[string]bool{"send": false})
+
+	var answers []bool
+	captureOutput(t, func() {
+		answers = append(answers,
+			confirmer.Confirm("wipe_corpses", "All corpses will be removed."),
+			confirmer.Confirm("wipe_corpses", "All corpses will be removed."),
+			confirmer.Confirm("send", "Opcode 20 will be sent."),
+			confirmer.Confirm("kick", "Bob will be kicked."),
+		)
+	})
+
+	want := []bool{false, true, true, true}
+	for i := range want {
+		if answers[i] != want[i] {
+			t.Errorf("answer %d = %t, want %t", i, answers[i], want[i])
+		}
+	}
+}
+
+func TestConfirmCommand(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	repl.confirmer = newTestConfirmer(t, "n\n", nil)
+
+	output := execute(t, repl, "wipe_corpses")
+	if !strings.Contains(output, "Cancelled.") {
+		t.Errorf("expected the command to be cancelled:\n%s", output)
+	}
+	execute(t, repl, "wipe_corpses --yes")
+
+	server.Lock()
+	defer server.Unlock()
+	if server.CorpseWipes != 1 {
+		t.Errorf("got %d corpse wipes, want 1", server.CorpseWipes)
+	}
+}
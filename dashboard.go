@@ -0,0 +1,522 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	rcon "github.com/butt4cak3/theislercon"
+	"github.com/chzyer/readline"
+)
+
+const (
+	dashboardInterval = 3 * time.Second
+	dashboardEvents   = 5
+	dashboardPanel    = 8
+)
+
+// dashboardPrompt asks the user for a line of text at the bottom of the
+// dashboard.
+type dashboardPrompt struct {
+	label  string
+	text   []rune
+	submit func(text string)
+}
+
+// Dashboard is a full-screen view of the server that refreshes itself. It
+// uses the same command functions as the REPL for everything that it does
+// with a player.
+type Dashboard struct {
+	*Poller
+	client     Client
+	wrapClient func(command string) Client
+	permission Permission
+	messages   MessagesConfig
+	zones      *ZoneMap
+	out        io.Writer
+
+	mutex    sync.Mutex
+	details  *rcon.ServerDetails
+	players  []rcon.Player
+	updated  time.Time
+	err      error
+	warning  string
+	selected string
+	events   []PlayerEvent
+	panel    []string
+	prompt   *dashboardPrompt
+	width    int
+	height   int
+
+	// active is true while the dashboard owns the screen
+	active bool
+}
+
+// NewDashboard creates a dashboard that polls client and draws to out.
+// Actions on players use the clients that wrapClient returns.
+func NewDashboard(client Client, wrapClient func(command string) Client, permission Permission, messages MessagesConfig, zones *ZoneMap, out io.Writer) *Dashboard {
+	d := &Dashboard{
+		client:     client,
+		wrapClient: wrapClient,
+		permission: permission,
+		messages:   messages,
+		zones:      zones,
+		out:        out,
+		width:      80,
+		height:     24,
+	}
+	d.Poller = NewPoller(dashboardInterval, d.Poll)
+	return d
+}
+
+// Poll fetches the server details and players and redraws the dashboard.
+func (d *Dashboard) Poll() error {
+	details, err := d.client.GetServerDetails()
+	var players []rcon.Player
+	if err == nil {
+		players, err = d.client.GetPlayerData()
+	}
+
+	d.mutex.Lock()
+	d.err = err
+	if err == nil {
+		slices.SortFunc(players, func(a, b rcon.Player) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		})
+		d.details = details
+		d.players = players
+		d.updated = time.Now()
+		if d.index() < 0 && len(players) > 0 {
+			d.selected = players[0].ID
+		}
+	}
+	d.mutex.Unlock()
+
+	d.draw()
+	return nil
+}
+
+// Handle adds joins and leaves to the ticker.
+func (d *Dashboard) Handle(event PlayerEvent) {
+	d.mutex.Lock()
+	d.events = append(d.events, event)
+	if len(d.events) > dashboardEvents {
+		d.events = d.events[len(d.events)-dashboardEvents:]
+	}
+	d.mutex.Unlock()
+
+	d.draw()
+}
+
+// Log shows a message from something that runs in the background. Nothing
+// may print while the dashboard runs, because the output of commands is
+// captured for the panel and everything else would end up there or mess up
+// the screen.
+func (d *Dashboard) Log(message string) {
+	d.mutex.Lock()
+	d.warning = message
+	d.mutex.Unlock()
+
+	d.draw()
+}
+
+// Run switches the terminal to full-screen mode and handles key presses until
+// the user quits.
+func (d *Dashboard) Run(in *os.File) error {
+	fd := int(in.Fd())
+	if !readline.IsTerminal(fd) {
+		return errors.New("the dashboard needs a terminal")
+	}
+
+	state, err := readline.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer readline.Restore(fd, state)
+
+	// Alternate screen without a cursor
+	fmt.Fprint(d.out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(d.out, "\x1b[?25h\x1b[?1049l")
+
+	d.mutex.Lock()
+	d.active = true
+	d.mutex.Unlock()
+	defer func() {
+		d.mutex.Lock()
+		d.active = false
+		d.mutex.Unlock()
+	}()
+
+	d.Start()
+	defer d.Stop()
+
+	buf := make([]byte, 64)
+	for {
+		if width, height, err := readline.GetSize(fd); err == nil && width > 0 && height > 0 {
+			d.mutex.Lock()
+			d.width, d.height = width, height
+			d.mutex.Unlock()
+		}
+		d.draw()
+
+		n, err := in.Read(buf)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		for _, key := range splitKeys(buf[:n]) {
+			if d.HandleKey(key) {
+				return nil
+			}
+		}
+	}
+}
+
+// splitKeys turns the bytes that were read from the terminal into single key
+// presses. Arrow keys are sent as escape sequences.
+func splitKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		if b[0] == 0x1b && len(b) >= 3 && b[1] == '[' {
+			keys = append(keys, string(b[:3]))
+			b = b[3:]
+			continue
+		}
+		r, size := utf8.DecodeRune(b)
+		keys = append(keys, string(r))
+		b = b[size:]
+	}
+	return keys
+}
+
+// HandleKey reacts to a single key press. It returns true if the user wants
+// to quit.
+func (d *Dashboard) HandleKey(key string) bool {
+	d.mutex.Lock()
+	prompt := d.prompt
+	d.mutex.Unlock()
+
+	if prompt != nil {
+		d.handlePromptKey(prompt, key)
+		return false
+	}
+
+	switch key {
+	case "q", "\x03", "\x04":
+		return true
+	case "\x1b[A", "k":
+		d.move(-1)
+	case "\x1b[B", "j":
+		d.move(1)
+	case "r":
+		d.Poll()
+	// The commands get the ID, because several players may have the same
+	// name, but the row says exactly who was picked
+	case "i", "\r":
+		d.withSelected(func(player rcon.Player) {
+			d.run("info", []string{player.ID}, d.infoCommand)
+		})
+	case "m":
+		d.withSelected(func(player rcon.Player) {
+			d.ask(fmt.Sprintf("Message to %s: ", player.Name), func(text string) {
+				if text == "" {
+					return
+				}
+				// messageCommand only prints something if it fails
+				if d.run("dm", []string{player.ID, text}, d.messageCommand) {
+					d.setPanel([]string{fmt.Sprintf("Message sent to %s", player.Name)})
+				}
+			})
+		})
+	case "x":
+		d.withSelected(func(player rcon.Player) {
+			d.ask(fmt.Sprintf("Kick %s? Reason (Enter for the default, Esc to cancel): ", player.Name), func(text string) {
+				args := []string{player.ID}
+				if text != "" {
+					args = append(args, text)
+				}
+				d.run("kick", args, kickCommand)
+				d.Poll()
+			})
+		})
+	}
+	return false
+}
+
+func (d *Dashboard) handlePromptKey(prompt *dashboardPrompt, key string) {
+	switch key {
+	case "\r", "\n":
+		d.mutex.Lock()
+		d.prompt = nil
+		d.mutex.Unlock()
+		prompt.submit(strings.TrimSpace(string(prompt.text)))
+	case "\x1b", "\x03":
+		d.mutex.Lock()
+		d.prompt = nil
+		d.mutex.Unlock()
+	case "\x7f", "\x08":
+		d.mutex.Lock()
+		if len(prompt.text) > 0 {
+			prompt.text = prompt.text[:len(prompt.text)-1]
+		}
+		d.mutex.Unlock()
+	default:
+		r, _ := utf8.DecodeRuneInString(key)
+		if len(key) == utf8.RuneLen(r) && unicode.IsPrint(r) {
+			d.mutex.Lock()
+			prompt.text = append(prompt.text, r)
+			d.mutex.Unlock()
+		}
+	}
+	d.draw()
+}
+
+func (d *Dashboard) ask(label string, submit func(text string)) {
+	d.mutex.Lock()
+	d.prompt = &dashboardPrompt{label: label, submit: submit}
+	d.mutex.Unlock()
+}
+
+// run calls a command function and shows everything that it prints in the
+// panel below the player table. It returns true if the command didn't print
+// anything and didn't fail.
+func (d *Dashboard) run(command string, args []string, f func(Client, []string) error) bool {
+	if required := requiredPermission(command, args); d.permission < required {
+		d.setPanel([]string{fmt.Sprintf("You are not allowed to do that. This requires %s permissions.", required)})
+		return false
+	}
+
+	line := command + " " + strings.Join(args, " ")
+	output, err := captureStdout(func() error {
+		return f(d.wrapClient(line), args)
+	})
+
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if output == "" {
+		lines = nil
+	}
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("%s command failed: %v", command, err))
+	}
+	d.setPanel(lines)
+	return len(lines) == 0
+}
+
+// infoCommand shows a player. Everyone in the dashboard is online, so there
+// is no need for the name cache.
+func (d *Dashboard) infoCommand(client Client, args []string) error {
+	return infoCommand(client, nil, d.zones, args)
+}
+
+// messageCommand sends a direct message, split like in the prompt.
+func (d *Dashboard) messageCommand(client Client, args []string) error {
+	return messageCommand(client, d.messages, args)
+}
+
+// captureStdout returns everything that f prints. It replaces os.Stdout
+// while f runs, which is why background tasks must use Log instead of
+// printing.
+func captureStdout(f func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		r.Close()
+		output <- string(b)
+	}()
+
+	err = f()
+	w.Close()
+	return <-output, err
+}
+
+func (d *Dashboard) setPanel(lines []string) {
+	d.mutex.Lock()
+	d.panel = lines
+	d.mutex.Unlock()
+	d.draw()
+}
+
+func (d *Dashboard) withSelected(f func(player rcon.Player)) {
+	d.mutex.Lock()
+	i := d.index()
+	var player rcon.Player
+	if i >= 0 {
+		player = d.players[i]
+	}
+	d.mutex.Unlock()
+
+	if i >= 0 {
+		f(player)
+	}
+}
+
+// index returns the position of the selected player in the table. The mutex
+// must be held.
+func (d *Dashboard) index() int {
+	return slices.IndexFunc(d.players, func(p rcon.Player) bool { return p.ID == d.selected })
+}
+
+func (d *Dashboard) move(delta int) {
+	d.mutex.Lock()
+	if len(d.players) > 0 {
+		i := min(max(d.index()+delta, 0), len(d.players)-1)
+		d.selected = d.players[i].ID
+	}
+	d.mutex.Unlock()
+	d.draw()
+}
+
+func (d *Dashboard) draw() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.active {
+		return
+	}
+
+	// The last line must not end with a line break, or a full screen scrolls
+	io.WriteString(d.out, "\x1b[H"+strings.Join(d.view(), "\x1b[K\r\n")+"\x1b[K\x1b[J")
+}
+
+// view returns the lines of the dashboard. The mutex must be held.
+func (d *Dashboard) view() []string {
+	var top, bottom []string
+
+	if d.details == nil {
+		top = append(top, "Loading...")
+	} else {
+		s := d.details
+		top = append(top,
+			fmt.Sprintf("%s  |  %s  |  %d/%d players  |  updated %s", s.Name, s.Map, s.CurrentPlayers, s.MaxPlayers, d.updated.Format(time.TimeOnly)),
+			fmt.Sprintf("Whitelist %s  Global chat %s  Humans %s  AI %s  Queue %s  Mutations %s",
+				onOff(s.Whitelist), onOff(s.EnableGlobalChat), onOff(s.EnableHumans), onOff(s.SpawnAI), onOff(s.QueueEnabled), onOff(s.EnableMutations)),
+			"Classes: "+classDistribution(d.players),
+		)
+	}
+	if d.err != nil {
+		top = append(top, fmt.Sprintf("Cannot refresh: %v", d.err))
+	}
+	if d.warning != "" {
+		top = append(top, d.warning)
+	}
+	header := fmt.Sprintf("  %-20s %-18s %-17s %-17s", "NAME", "CLASS", "GROWTH", "HEALTH")
+	if d.zones != nil {
+		header += " ZONE"
+	}
+	top = append(top, "", strings.TrimRight(header, " "))
+
+	var ticker []string
+	for i := len(d.events) - 1; i >= 0; i-- {
+		e := d.events[i]
+		ticker = append(ticker, fmt.Sprintf("%s %s %s", e.Time.Format(time.TimeOnly), e.Player.Name, strings.ToLower(e.Type.String())))
+	}
+	bottom = append(bottom, "", "Recent: "+strings.Join(ticker, "  |  "))
+	if len(d.panel) > 0 {
+		panel := d.panel
+		if len(panel) > dashboardPanel {
+			panel = panel[:dashboardPanel]
+		}
+		bottom = append(bottom, "")
+		bottom = append(bottom, panel...)
+	}
+	bottom = append(bottom, "")
+	if d.prompt != nil {
+		bottom = append(bottom, d.prompt.label+string(d.prompt.text)+"_")
+	} else {
+		bottom = append(bottom, "Up/Down select  i inspect  m message  x kick  r refresh  q quit")
+	}
+
+	// Scroll the table so that the selected player is always visible
+	rows := max(d.height-len(top)-len(bottom), 1)
+	first := 0
+	if selected := d.index(); selected >= rows {
+		first = selected - rows + 1
+	}
+
+	lines := top
+	for i := first; i < len(d.players) && i < first+rows; i++ {
+		p := d.players[i]
+		cursor := " "
+		if p.ID == d.selected {
+			cursor = ">"
+		}
+		line := fmt.Sprintf("%s %s %-18s %s %s", cursor, padRight(truncate(p.Name, 20), 20), p.DinoClass.Name(), bar(p.Growth), bar(p.Health))
+		if zone := d.zones.Describe(p.Location); zone != "" {
+			line += " " + zone
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, bottom...)
+
+	for i, line := range lines {
+		lines[i] = truncate(line, d.width)
+	}
+	return lines
+}
+
+// classDistribution returns how many players play each class, most popular
+// first.
+func classDistribution(players []rcon.Player) string {
+	counts := make(map[rcon.DinoClass]int)
+	for _, p := range players {
+		counts[p.DinoClass]++
+	}
+	classes := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(classes, func(a, b rcon.DinoClass) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(string(a), string(b))
+	})
+
+	parts := make([]string, len(classes))
+	for i, class := range classes {
+		parts[i] = fmt.Sprintf("%s %d", class.Name(), counts[class])
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// bar draws a percentage as a bar that is 10 characters wide.
+func bar(percent int8) string {
+	filled := min(max(int(percent), 0), 100) / 10
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat("-", 10-filled), percent)
+}
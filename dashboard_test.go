@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func newTestDashboard(t *testing.T, permission Permission) (*Dashboard, *fakeserver.Server) {
+	t.Helper()
+	repl, server := newTestRepl(t, permission)
+	d := NewDashboard(repl.wrapClient("top"), repl.wrapClient, permission, MessagesConfig{}, nil, io.Discard)
+	if err := d.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	return d, server
+}
+
+func typeKeys(d *Dashboard, keys ...string) {
+	for _, key := range keys {
+		for _, k := range splitKeys([]byte(key)) {
+			d.HandleKey(k)
+		}
+	}
+}
+
+func TestDashboardView(t *testing.T) {
+	d, _ := newTestDashboard(t, PermissionAdmin)
+
+	view := strings.Join(d.view(), "\n")
+	for _, want := range []string{
+		"Fake Server  |  Gateway  |  2/100 players",
+		"Whitelist off  Global chat on",
+		"Classes: Carnotaurus 1, Stegosaurus 1",
+		"> Alice                Carnotaurus        [#######---]  75% [##########] 100%",
+		"  Bob                  Stegosaurus        [#####-----]  50%",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in view:\n%s", want, view)
+		}
+	}
+}
+
+func TestDashboardActions(t *testing.T) {
+	d, server := newTestDashboard(t, PermissionModerator)
+
+	// Select Bob and look at him
+	typeKeys(d, "\x1b[B", "i")
+	if panel := strings.Join(d.panel, "\n"); !strings.Contains(panel, "Player Bob") {
+		t.Errorf("expected info about Bob:\n%s", panel)
+	}
+
+	typeKeys(d, "m", "Hi Bob", "\r")
+	typeKeys(d, "x", "Go away", "\x7f\x7f\x7f\x7f", "\r")
+
+	server.Lock()
+	defer server.Unlock()
+	if want := []fakeserver.Message{{PlayerID: bob.ID, Text: "Hi Bob"}}; !slices.Equal(server.DirectMessages, want) {
+		t.Errorf("got direct messages %+v, want %+v", server.DirectMessages, want)
+	}
+	if want := []fakeserver.Message{{PlayerID: bob.ID, Text: "Go"}}; !slices.Equal(server.Kicks, want) {
+		t.Errorf("got kicks %+v, want %+v", server.Kicks, want)
+	}
+}
+
+func TestDashboardSameNames(t *testing.T) {
+	d, server := newTestDashboard(t, PermissionModerator)
+	other := rcon.Player{ID: "76561198000000005", Name: "Bob"}
+	server.AddPlayer(other)
+	d.Poll()
+
+	d.selected = other.ID
+	typeKeys(d, "i")
+	if panel := strings.Join(d.panel, "\n"); !strings.Contains(panel, other.ID) {
+		t.Errorf("expected info about the selected Bob:\n%s", panel)
+	}
+	typeKeys(d, "x", "\r")
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Kicks) != 1 || server.Kicks[0].PlayerID != other.ID {
+		t.Errorf("got kicks %+v, want the selected Bob", server.Kicks)
+	}
+}
+
+func TestDashboardLog(t *testing.T) {
+	d, _ := newTestDashboard(t, PermissionAdmin)
+	d.Log("Warning: cannot write audit log")
+	if view := strings.Join(d.view(), "\n"); !strings.Contains(view, "Warning: cannot write audit log") {
+		t.Errorf("expected the warning in view:\n%s", view)
+	}
+}
+
+func TestDashboardPermissions(t *testing.T) {
+	d, server := newTestDashboard(t, PermissionReadOnly)
+
+	typeKeys(d, "x", "\r")
+	if panel := strings.Join(d.panel, "\n"); !strings.Contains(panel, "not allowed") {
+		t.Errorf("expected the kick to be refused:\n%s", panel)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Kicks) != 0 {
+		t.Errorf("nobody should have been kicked, got %+v", server.Kicks)
+	}
+}
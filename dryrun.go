@@ -0,0 +1,143 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// readOnlyCommands are the message types that don't change anything on the
+// server.
+var readOnlyCommands = map[byte]bool{
+	rcon.GetServerDetails: true,
+	rcon.GetPlayerList:    true,
+	rcon.GetPlayerData:    true,
+}
+
+// NewDryRunClient wraps a client so that every call that would change the
+// state of the server is printed to out instead of being sent. Calls that
+// only read data are passed through, so that things like resolving player
+// names still work.
+func NewDryRunClient(client Client, out io.Writer) Client {
+	return &dryRunClient{client, out}
+}
+
+type dryRunClient struct {
+	Client
+	out io.Writer
+}
+
+func (c *dryRunClient) print(action string, args ...any) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			quoted[i] = fmt.Sprintf("%q", s)
+		} else {
+			quoted[i] = fmt.Sprintf("%v", arg)
+		}
+	}
+	fmt.Fprintf(c.out, "[dry run] %s(%s)\n", action, strings.Join(quoted, ", "))
+}
+
+// toggle prints the action and returns the state that the server would be in
+// after the toggle.
+func (c *dryRunClient) toggle(action string, current func(*rcon.ServerDetails) bool) (bool, error) {
+	c.print(action)
+	details, err := c.Client.GetServerDetails()
+	if err != nil {
+		return false, err
+	}
+	return !current(details), nil
+}
+
+func (c *dryRunClient) Announce(message string) error {
+	c.print("Announce", message)
+	return nil
+}
+
+func (c *dryRunClient) SendDirectMessage(playerID, message string) error {
+	c.print("SendDirectMessage", playerID, message)
+	return nil
+}
+
+func (c *dryRunClient) KickPlayer(playerID, reason string) error {
+	c.print("KickPlayer", playerID, reason)
+	return nil
+}
+
+func (c *dryRunClient) WipeCorpses() error {
+	c.print("WipeCorpses")
+	return nil
+}
+
+func (c *dryRunClient) UpdatePlayables(classes []rcon.DinoClass) error {
+	c.print("UpdatePlayables", classes)
+	return nil
+}
+
+func (c *dryRunClient) ToggleWhitelist() (bool, error) {
+	return c.toggle("ToggleWhitelist", func(d *rcon.ServerDetails) bool { return d.Whitelist })
+}
+
+func (c *dryRunClient) AddWhitelistID(playerID ...string) error {
+	c.print("AddWhitelistID", playerID)
+	return nil
+}
+
+func (c *dryRunClient) RemoveWhitelistID(playerID ...string) error {
+	c.print("RemoveWhitelistID", playerID)
+	return nil
+}
+
+func (c *dryRunClient) ToggleGlobalChat() (bool, error) {
+	return c.toggle("ToggleGlobalChat", func(d *rcon.ServerDetails) bool { return d.EnableGlobalChat })
+}
+
+func (c *dryRunClient) ToggleHumans() (bool, error) {
+	return c.toggle("ToggleHumans", func(d *rcon.ServerDetails) bool { return d.EnableHumans })
+}
+
+func (c *dryRunClient) ToggleAI() (bool, error) {
+	return c.toggle("ToggleAI", func(d *rcon.ServerDetails) bool { return d.SpawnAI })
+}
+
+func (c *dryRunClient) DisableAIClasses(classes []rcon.AIClass) error {
+	c.print("DisableAIClasses", classes)
+	return nil
+}
+
+func (c *dryRunClient) SetAIDensity(density float32) error {
+	c.print("SetAIDensity", density)
+	return nil
+}
+
+func (c *dryRunClient) ExecCommand(command byte, params ...string) (string, error) {
+	if readOnlyCommands[command] {
+		return c.Client.ExecCommand(command, params...)
+	}
+	if rcon.MessageType(command) == rcon.BanPlayer {
+		c.print("BanPlayer", params)
+		return "", nil
+	}
+	c.print(fmt.Sprintf("ExecCommand[%02x]", command), params)
+	return "", nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func TestDryRunClient(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	var out strings.Builder
+	wrapClient := repl.wrapClient
+	repl.wrapClient = func(command string) Client {
+		return NewDryRunClient(wrapClient(command), &out)
+	}
+
+	execute(t, repl, "kick Bob Bye")
+	execute(t, repl, "whitelist toggle")
+
+	for _, want := range []string{`[dry run] KickPlayer("76561198000000002", "Bye")`, "[dry run] ToggleWhitelist()"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Kicks) != 0 || server.Details.Whitelist {
+		t.Errorf("nothing should be changed on the server, got kicks %v", server.Kicks)
+	}
+	if len(server.Players) != 2 {
+		t.Errorf("got %d players, want 2", len(server.Players))
+	}
+}
+
+func TestDryRunDoesNotSaveState(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	client := NewDryRunClient(repl.wrapClient("test"), &strings.Builder{})
+	dir := t.TempDir()
+
+	capsPath := filepath.Join(dir, "caps.json")
+	caps, err := NewCapWatcher(client, CapsConfig{DryRun: true}, capsPath, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := caps.SetAllowed(client, []rcon.DinoClass{rcon.Carnotaurus}); err != nil {
+		t.Fatal(err)
+	}
+
+	greeterPath := filepath.Join(dir, "greeter.json")
+	greeter, err := NewGreeter(client, GreeterConfig{DryRun: true, WelcomeMessage: "Hi"}, greeterPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := greeter.Greet(alice, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{capsPath, greeterPath} {
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s should not be written in a dry run", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+const defaultExporterAddress = ":9105"
+
+type requestStats struct {
+	count   int
+	errors  int
+	seconds float64
+}
+
+// Exporter serves metrics about the server in the Prometheus text format. The
+// server is polled whenever Prometheus scrapes the metrics.
+type Exporter struct {
+	client Client
+
+	mutex    sync.Mutex
+	requests map[string]*requestStats
+}
+
+func NewExporter(client Client) *Exporter {
+	return &Exporter{
+		client:   client,
+		requests: make(map[string]*requestStats),
+	}
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteMetrics(w)
+}
+
+// observe calls f and records how long it took and whether it failed.
+func (e *Exporter) observe(action string, f func() error) error {
+	start := time.Now()
+	err := f()
+	seconds := time.Since(start).Seconds()
+
+	stats, ok := e.requests[action]
+	if !ok {
+		stats = &requestStats{}
+		e.requests[action] = stats
+	}
+	stats.count++
+	stats.seconds += seconds
+	if err != nil {
+		stats.errors++
+	}
+	return err
+}
+
+// WriteMetrics polls the server and writes all metrics to w.
+func (e *Exporter) WriteMetrics(w io.Writer) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	start := time.Now()
+
+	var details *rcon.ServerDetails
+	var players []rcon.Player
+	err := e.observe("GetServerDetails", func() (err error) {
+		details, err = e.client.GetServerDetails()
+		return err
+	})
+	if err == nil {
+		err = e.observe("GetPlayerData", func() (err error) {
+			players, err = e.client.GetPlayerData()
+			return err
+		})
+	}
+
+	m := &metricsWriter{w: w}
+
+	if err == nil {
+		m.gauge("evrima_players", "Number of players that are online.", float64(details.CurrentPlayers))
+		m.gauge("evrima_max_players", "Maximum number of players.", float64(details.MaxPlayers))
+
+		perClass := make(map[rcon.DinoClass]int)
+		growth := 0
+		for _, player := range players {
+			perClass[player.DinoClass]++
+			growth += int(player.Growth)
+		}
+		m.header("evrima_class_players", "Number of players that are online per class.", "gauge")
+		for _, class := range rcon.AllClasses {
+			m.sample("evrima_class_players", map[string]string{"class": string(class)}, float64(perClass[class]))
+		}
+
+		average := 0.0
+		if len(players) > 0 {
+			average = float64(growth) / float64(len(players)) / 100
+		}
+		m.gauge("evrima_average_growth_ratio", "Average growth of all players that are online, from 0 to 1.", average)
+
+		m.gauge("evrima_whitelist_enabled", "Whether the whitelist is on.", boolValue(details.Whitelist))
+		m.gauge("evrima_global_chat_enabled", "Whether the global chat is on.", boolValue(details.EnableGlobalChat))
+		m.gauge("evrima_queue_enabled", "Whether the queue is on.", boolValue(details.QueueEnabled))
+	}
+
+	actions := make([]string, 0, len(e.requests))
+	for action := range e.requests {
+		actions = append(actions, action)
+	}
+	slices.Sort(actions)
+
+	m.header("pteroprompt_rcon_requests_total", "Number of RCON requests that the exporter sent.", "counter")
+	for _, action := range actions {
+		m.sample("pteroprompt_rcon_requests_total", map[string]string{"action": action}, float64(e.requests[action].count))
+	}
+	m.header("pteroprompt_rcon_errors_total", "Number of RCON requests that failed.", "counter")
+	for _, action := range actions {
+		m.sample("pteroprompt_rcon_errors_total", map[string]string{"action": action}, float64(e.requests[action].errors))
+	}
+	m.header("pteroprompt_rcon_request_duration_seconds", "How long RCON requests took.", "summary")
+	for _, action := range actions {
+		labels := map[string]string{"action": action}
+		m.sample("pteroprompt_rcon_request_duration_seconds_sum", labels, e.requests[action].seconds)
+		m.sample("pteroprompt_rcon_request_duration_seconds_count", labels, float64(e.requests[action].count))
+	}
+
+	m.gauge("pteroprompt_scrape_success", "Whether the server could be polled.", boolValue(err == nil))
+	m.gauge("pteroprompt_scrape_duration_seconds", "How long polling the server took.", time.Since(start).Seconds())
+}
+
+// metricsWriter writes the Prometheus text format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (m *metricsWriter) header(name, help, typ string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m *metricsWriter) sample(name string, labels map[string]string, value float64) {
+	if len(labels) == 0 {
+		fmt.Fprintf(m.w, "%s %g\n", name, value)
+		return
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", key, escapeLabel(labels[key]))
+	}
+	fmt.Fprintf(m.w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+}
+
+func (m *metricsWriter) gauge(name, help string, value float64) {
+	m.header(name, help, "gauge")
+	m.sample(name, nil, value)
+}
+
+var labelEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
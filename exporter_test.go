@@ -0,0 +1,85 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, exporter *Exporter) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	exporter.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	return string(body)
+}
+
+func TestExporter(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionReadOnly)
+	server.Lock()
+	server.Details.Whitelist = true
+	server.Unlock()
+
+	exporter := NewExporter(repl.wrapClient("exporter"))
+	metrics := scrape(t, exporter)
+
+	for _, want := range []string{
+		"# TYPE evrima_players gauge\nevrima_players 2\n",
+		"evrima_max_players 100\n",
+		"evrima_class_players{class=\"Carnotaurus\"} 1\n",
+		"evrima_class_players{class=\"Troodon\"} 0\n",
+		"evrima_average_growth_ratio 0.625\n",
+		"evrima_whitelist_enabled 1\n",
+		"evrima_global_chat_enabled 1\n",
+		"evrima_queue_enabled 0\n",
+		"pteroprompt_rcon_requests_total{action=\"GetPlayerData\"} 1\n",
+		"pteroprompt_rcon_errors_total{action=\"GetServerDetails\"} 0\n",
+		"pteroprompt_rcon_request_duration_seconds_count{action=\"GetServerDetails\"} 1\n",
+		"pteroprompt_scrape_success 1\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("expected %q in metrics:\n%s", want, metrics)
+		}
+	}
+
+	server.Close()
+	server.Disconnect()
+
+	metrics = scrape(t, exporter)
+	for _, want := range []string{
+		"pteroprompt_rcon_requests_total{action=\"GetServerDetails\"} 2\n",
+		"pteroprompt_rcon_errors_total{action=\"GetServerDetails\"} 1\n",
+		"pteroprompt_scrape_success 0\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("expected %q in metrics:\n%s", want, metrics)
+		}
+	}
+	if strings.Contains(metrics, "evrima_players") {
+		t.Errorf("server metrics should be missing when the scrape failed:\n%s", metrics)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got, want := escapeLabel("a\"b\\c\nd"), "a\\\"b\\\\c\\nd"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// maxFindResults is how many players the find command shows.
+const maxFindResults = 10
+
+// maxSuggestions is how many names are suggested if a player isn't found.
+const maxSuggestions = 3
+
+// PlayerMatch is a player whose name is similar to a search query. Higher
+// scores are better matches.
+type PlayerMatch struct {
+	Player rcon.Player
+	Score  int
+}
+
+// FindPlayers returns the players whose names match query, best matches
+// first. Names that contain query are always found, others only if they
+// are off by a few typos.
+func FindPlayers(players []rcon.Player, query string) []PlayerMatch {
+	query = normalizeName(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	var matches []PlayerMatch
+	for _, player := range players {
+		if score := matchScore(normalizeName(player.Name), query); score > 0 {
+			matches = append(matches, PlayerMatch{player, score})
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b PlayerMatch) int {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return strings.Compare(a.Player.Name, b.Player.Name)
+	})
+	return matches
+}
+
+// matchScore rates how well name matches query. Both have to be normalized.
+// A score of 0 means that they don't match at all.
+func matchScore(name, query string) int {
+	switch {
+	case name == query:
+		return 100
+	case strings.HasPrefix(name, query):
+		return 90
+	case isWordStart(name, query):
+		// Names often start with clan tags, like "[ABC] Rexy"
+		return 80
+	case strings.Contains(name, query):
+		return 70
+	}
+
+	// Allow one typo for every four characters
+	q := []rune(query)
+	allowed := len(q) / 4
+	if allowed == 0 {
+		return 0
+	}
+	// Compare the query with every part of the name that has about the
+	// same length
+	n := []rune(name)
+	distance := editDistance(n, q)
+	for size := max(1, len(q)-allowed); size <= len(q)+allowed; size++ {
+		for start := 0; start+size <= len(n); start++ {
+			distance = min(distance, editDistance(n[start:start+size], q))
+		}
+	}
+	if distance > allowed {
+		return 0
+	}
+	return 60 - 10*distance
+}
+
+// isWordStart returns true if query appears at the start of a word in name
+// that isn't the first one.
+func isWordStart(name, query string) bool {
+	for i, r := range name {
+		// Invalid bytes are decoded as utf8.RuneError, which is longer than
+		// the byte itself
+		_, size := utf8.DecodeRuneInString(name[i:])
+		if i > 0 && !unicode.IsLetter(r) && !unicode.IsDigit(r) && strings.HasPrefix(name[i+size:], query) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance returns the number of characters that have to be inserted,
+// removed or replaced to turn a into b. Swapping two neighbouring characters
+// counts as one typo.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// printSuggestions tells the user which players they might have meant if
+// name doesn't belong to anyone.
+func printSuggestions(players []rcon.Player, name string) {
+	matches := FindPlayers(players, name)
+	if len(matches) == 0 {
+		return
+	}
+	names := make([]string, 0, maxSuggestions)
+	for _, match := range matches[:min(len(matches), maxSuggestions)] {
+		names = append(names, fmt.Sprintf("\"%s\"", match.Player.Name))
+	}
+	fmt.Printf("Did you mean %s?\n", strings.Join(names, ", "))
+}
+
+// playerNotFound reports that there is no player with name and suggests
+// players with similar names.
+func playerNotFound(client Client, name string) error {
+	fmt.Printf("Player \"%s\" not found\n", name)
+	players, err := client.GetPlayerList()
+	if err != nil {
+		return err
+	}
+	printSuggestions(players, name)
+	return nil
+}
+
+// playerAmbiguous reports that several players that are online have names
+// like name and lists them.
+func playerAmbiguous(client Client, name string) error {
+	players, err := client.GetPlayerList()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Several players are called \"%s\". Use one of their IDs instead:\n", name)
+	for _, player := range matchingPlayers(players, name) {
+		fmt.Printf("    %s  %s\n", player.ID, player.Name)
+	}
+	return nil
+}
+
+func findCommand(client Client, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("Missing QUERY")
+		return nil
+	}
+
+	players, err := client.GetPlayerData()
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(args, " ")
+	matches := FindPlayers(players, query)
+	if len(matches) == 0 {
+		fmt.Printf("No players match \"%s\"\n", query)
+		return nil
+	}
+
+	for _, match := range matches[:min(len(matches), maxFindResults)] {
+		p := match.Player
+		fmt.Printf("    %s %-20s %s\n", padRight(p.Name, 24), p.ID, p.DinoClass.Name())
+	}
+	if len(matches) > maxFindResults {
+		fmt.Printf("    and %d more\n", len(matches)-maxFindResults)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func TestFindPlayers(t *testing.T) {
+	players := []rcon.Player{
+		{ID: "1", Name: "Rex"},
+		{ID: "2", Name: "[PACK] Rexy"},
+		{ID: "3", Name: "Rexford"},
+		{ID: "4", Name: "T-Rex Hunter"},
+		{ID: "5", Name: "Stego"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"rex", []string{"Rex", "Rexford", "T-Rex Hunter", "[PACK] Rexy"}},
+		{"[pack] REXY", []string{"[PACK] Rexy"}},
+		{"stega", []string{"Stego"}},
+		{"rexfrod", []string{"Rexford"}},
+		{"xyz", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, match := range FindPlayers(players, test.query) {
+			got = append(got, match.Player.Name)
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("FindPlayers(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestFindInvalidNames(t *testing.T) {
+	// Names that aren't valid UTF-8 must not crash the search
+	players := []rcon.Player{{Name: "ab\xff"}, {Name: "a\xffrex"}}
+	FindPlayers(players, "zzzzzzzz")
+	if matches := FindPlayers(players, "rex"); len(matches) != 1 || matches[0].Player.Name != "a\xffrex" {
+		t.Errorf("got %v", matches)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"alcie", "alice", 1},
+		{"rexy", "rexy", 0},
+		{"🦖rex", "rex", 1},
+	}
+	for _, test := range tests {
+		if got := editDistance([]rune(test.a), []rune(test.b)); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionReadOnly)
+
+	output := execute(t, repl, "find ali")
+	if !strings.Contains(output, alice.ID) || !strings.Contains(output, "Carnotaurus") {
+		t.Errorf("got %q, want Alice with ID and class", output)
+	}
+	if strings.Contains(output, bob.Name) {
+		t.Errorf("got %q, Bob doesn't match", output)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	for _, line := range []string{"info Alcie", "dm Alcie Hello", "kick Alcie"} {
+		output := execute(t, repl, line)
+		if !strings.Contains(output, `Did you mean "Alice"?`) {
+			t.Errorf("%s: got %q, want a suggestion", line, output)
+		}
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.DirectMessages) != 0 || len(server.Kicks) != 0 {
+		t.Errorf("a misspelled name must not be used")
+	}
+}
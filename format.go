@@ -0,0 +1,72 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// parseFormatFlag looks for "--format TEMPLATE" in args. The REPL splits
+// lines at spaces, so everything after the flag is the template. Quotes
+// around the template are removed. ok is false if the template is invalid,
+// which has already been reported to the user.
+func parseFormatFlag(args []string) (tmpl *template.Template, rest []string, ok bool) {
+	i := slices.Index(args, "--format")
+	if i < 0 {
+		return nil, args, true
+	}
+
+	text := trimQuotes(strings.Join(args[i+1:], " "))
+	if text == "" {
+		fmt.Println("Missing TEMPLATE")
+		return nil, nil, false
+	}
+
+	tmpl, err := template.New("format").Parse(text)
+	if err != nil {
+		fmt.Printf("Invalid format: %v\n", err)
+		return nil, nil, false
+	}
+
+	return tmpl, args[:i], true
+}
+
+// trimQuotes removes single or double quotes around text.
+func trimQuotes(text string) string {
+	if len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0] {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
+
+// printTemplate prints a single line by executing tmpl with data.
+func printTemplate(tmpl *template.Template, data any) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		fmt.Printf("Invalid format: %v\n", err)
+		return
+	}
+	line := b.String()
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	fmt.Print(line)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+type GreeterConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// WelcomeMessage is sent to players that have never been seen before.
+	// WelcomeBackMessage is sent to everyone else. Both may contain the
+	// placeholders {name}, {players} and {server}.
+	WelcomeMessage     string `json:"welcome_message"`
+	WelcomeBackMessage string `json:"welcome_back_message"`
+
+	// IntervalHours is the minimum time between two messages to the same
+	// player.
+	IntervalHours float64 `json:"interval_hours"`
+
+	// DryRun is set by --dry-run. Changes are kept in memory and not saved.
+	DryRun bool `json:"-"`
+}
+
+// greeterEntry is what the greeter remembers about a player.
+type greeterEntry struct {
+	FirstSeen   time.Time `json:"first_seen"`
+	LastGreeted time.Time `json:"last_greeted"`
+}
+
+// Greeter sends a direct message to players when they join the server.
+type Greeter struct {
+	client  Client
+	config  GreeterConfig
+	path    string
+	onError func(error)
+
+	mutex   sync.Mutex
+	entries map[string]greeterEntry
+}
+
+// NewGreeter creates a greeter that stores the players it has seen in the
+// file at path.
+func NewGreeter(client Client, config GreeterConfig, path string, onError func(error)) (*Greeter, error) {
+	g := &Greeter{
+		client:  client,
+		config:  config,
+		path:    path,
+		onError: onError,
+		entries: make(map[string]greeterEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return g, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &g.entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Handle is meant to be subscribed to a PlayerWatcher.
+func (g *Greeter) Handle(event PlayerEvent) {
+	if event.Type != PlayerJoined {
+		return
+	}
+
+	err := g.Greet(event.Player, event.Time)
+	if err != nil && g.onError != nil {
+		g.onError(err)
+	}
+}
+
+// Greet sends the welcome message to a player, unless they have already been
+// greeted within the configured interval.
+func (g *Greeter) Greet(player rcon.Player, now time.Time) error {
+	g.mutex.Lock()
+	entry, known := g.entries[player.ID]
+	interval := time.Duration(g.config.IntervalHours * float64(time.Hour))
+	g.mutex.Unlock()
+	if known && now.Sub(entry.LastGreeted) < interval {
+		return nil
+	}
+
+	template := g.config.WelcomeBackMessage
+	if !known {
+		template = g.config.WelcomeMessage
+	}
+
+	details, err := g.client.GetServerDetails()
+	if err != nil {
+		return err
+	}
+
+	message := strings.NewReplacer(
+		"{name}", player.Name,
+		"{players}", strconv.Itoa(details.CurrentPlayers),
+		"{server}", details.Name,
+	).Replace(template)
+
+	err = g.client.SendDirectMessage(player.ID, message)
+	if err != nil {
+		return err
+	}
+
+	// Players are only remembered once the message went through, so they
+	// are greeted again the next time if it didn't
+	if !known {
+		entry.FirstSeen = now
+	}
+	entry.LastGreeted = now
+	g.mutex.Lock()
+	g.entries[player.ID] = entry
+	g.mutex.Unlock()
+
+	return g.save()
+}
+
+func (g *Greeter) save() error {
+	if g.config.DryRun {
+		return nil
+	}
+
+	g.mutex.Lock()
+	data, err := json.MarshalIndent(g.entries, "", "  ")
+	g.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(g.path, data, 0644)
+}
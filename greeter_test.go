@@ -0,0 +1,85 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+)
+
+func newTestGreeter(t *testing.T) (*Greeter, *fakeserver.Server) {
+	t.Helper()
+
+	repl, server := newTestRepl(t, PermissionAdmin)
+	server.Details.Name = "Test Server"
+
+	config := GreeterConfig{
+		Enabled:            true,
+		WelcomeMessage:     "Welcome to {server}, {name}!",
+		WelcomeBackMessage: "Welcome back, {name}. {players} players are online.",
+		IntervalHours:      1,
+	}
+	greeter, err := NewGreeter(repl.wrapClient("greeter"), config, filepath.Join(t.TempDir(), "greeter.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return greeter, server
+}
+
+func TestGreet(t *testing.T) {
+	greeter, server := newTestGreeter(t)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// New players get the welcome message, returning players only get the
+	// welcome back message once the interval has passed
+	for _, at := range []time.Time{now, now.Add(30 * time.Minute), now.Add(2 * time.Hour)} {
+		if err := greeter.Greet(alice, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	want := []fakeserver.Message{
+		{PlayerID: alice.ID, Text: "Welcome to Test Server, Alice!"},
+		{PlayerID: alice.ID, Text: "Welcome back, Alice. 2 players are online."},
+	}
+	if len(server.DirectMessages) != len(want) {
+		t.Fatalf("got messages %+v, want %+v", server.DirectMessages, want)
+	}
+	for i := range want {
+		if server.DirectMessages[i] != want[i] {
+			t.Errorf("message %d: got %+v, want %+v", i, server.DirectMessages[i], want[i])
+		}
+	}
+}
+
+func TestGreetFailed(t *testing.T) {
+	greeter, server := newTestGreeter(t)
+	server.Close()
+	server.Disconnect()
+
+	if err := greeter.Greet(alice, time.Now()); err == nil {
+		t.Fatal("expected an error when the server is gone")
+	}
+	if _, ok := greeter.entries[alice.ID]; ok {
+		t.Error("a player that wasn't greeted should not be remembered")
+	}
+}
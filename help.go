@@ -17,9 +17,12 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
-func helpCommand(args []string) error {
+func helpCommand(macros *Macros, args []string) error {
 	if len(args) == 0 {
 		fmt.Println("Available commands:")
 		fmt.Println("    help           Show a list of all commands or details for a specific command")
@@ -27,19 +30,43 @@ func helpCommand(args []string) error {
 		fmt.Println("    announce       Send a message to all connected players")
 		fmt.Println("    players        Show a list of all connected players")
 		fmt.Println("    dm             Send a direct message to a specific player")
+		fmt.Println("    templates      Shows the messages that announce and dm can send with @NAME")
 		fmt.Println("    info           Show detailed information about a specific player")
+		fmt.Println("    find           Search for players whose names are similar to a text")
 		fmt.Println("    classes        Manages the list of allowed classes")
+		fmt.Println("    caps           Limits the number of players per class")
 		fmt.Println("    whitelist      Manages the whitelist")
 		fmt.Println("    kick           Kicks a player from the server")
+		fmt.Println("    ban            Bans a player from the server")
 		fmt.Println("    wipe_corspes   Removes all corpses from the map")
 		fmt.Println("    toggle_gc      Toggles the global chat")
 		fmt.Println("    toggle_humans  Toggles the humans feature")
 		fmt.Println("    ai             Manages AI spawning")
+		fmt.Println("    watch          Shows players joining and leaving the server")
+		fmt.Println("    rules          Checks players against the server rules")
+		fmt.Println("    seen           Shows when a player was last seen on the server")
+		fmt.Println("    playtime       Shows how long a player has played on the server")
+		fmt.Println("    top            Shows the players with the most playtime")
+		fmt.Println("    audit          Shows who did what on the server")
 		fmt.Println("    send           Send custom commands")
+		fmt.Println("    opcodes        Shows the opcodes that the send command knows")
+		fmt.Println("    probe          Sends a range of opcodes to find unknown commands")
+		fmt.Println("    alias          Gives a command a shorter name")
+		fmt.Println("    macro          Runs several commands at once")
 		fmt.Println("    quit           Exit the program")
 		fmt.Println()
+		if names := macros.Names(); len(names) > 0 {
+			fmt.Println("Your aliases and macros:")
+			for _, name := range names {
+				description, _ := macros.Describe(name)
+				fmt.Printf("    %-14s %s\n", name, description)
+			}
+			fmt.Println()
+		}
 		fmt.Println("You can type \"help COMMAND\" to get more information about a specific command.")
 		fmt.Println("For example, if you want to know more about the announce command, type \"help announce\".")
+		fmt.Println()
+		fmt.Println("Commands that are hard to undo ask for confirmation first. Add \"--yes\" to the end of a command to skip the question.")
 	} else {
 		switch args[0] {
 		case "help":
@@ -55,42 +82,104 @@ func helpCommand(args []string) error {
 		case "status":
 			fmt.Println("The status command shows some information about the server, like the number of currently connected players.")
 			fmt.Println()
-			fmt.Println("Usage: status")
+			fmt.Println("Usage: status [--format TEMPLATE]")
+			fmt.Println()
+			fmt.Println("Options:")
+			fmt.Println("    --format TEMPLATE  Print the server details with a Go template instead, e.g. '{{.CurrentPlayers}}/{{.MaxPlayers}}'")
+			fmt.Println()
+			fmt.Println("Fields: Name, Map, MaxPlayers, CurrentPlayers, EnableMutations, EnableHumans, HasPassword, QueueEnabled, Whitelist,")
+			fmt.Println("SpawnAI, AllowRecordingGameplay, UseRegionSpawning, UseRegionSpawnCooldown, RegionSpawnCooldownTimeSeconds,")
+			fmt.Println("DayLengthMinutes, NightLengthMinutes, EnableGlobalChat")
 		case "announce":
 			fmt.Println("The announce command sends an announcement message to all players on the server. The message will pop up as a big text box at the top of the screen.")
 			fmt.Println()
 			fmt.Println("Usage: announce MESSAGE")
+			fmt.Println("       announce @TEMPLATE [KEY=VALUE...]")
 			fmt.Println()
 			fmt.Println("Arguments:")
-			fmt.Println("    MESSAGE  The text you want to send to all players")
+			fmt.Println("    MESSAGE    The text you want to send to all players")
+			fmt.Println("    TEMPLATE   The name of a message template, see \"help templates\"")
+			fmt.Println("    KEY=VALUE  Fills the placeholder {KEY} in the template")
+			fmt.Println()
+			fmt.Println("Type \\n to start a new line. Long messages are split into several announcements.")
+			fmt.Println("To type a message over several lines, end the command with <<EOF and finish the message with a line that only contains EOF.")
 			fmt.Println()
 			fmt.Println("Example: Announce a server restart")
 			fmt.Println("    announce The server will restart in 10 minutes!")
+			fmt.Println("    announce @restart minutes=10")
+			fmt.Println()
+			fmt.Println("Example: Announce the rules")
+			fmt.Println("    announce <<EOF")
+			fmt.Println("    1. No killing at water access")
+			fmt.Println("    2. No camping")
+			fmt.Println("    EOF")
 		case "players":
-			fmt.Println("The players command shows a list of all currently connected users.")
+			fmt.Println("The players command shows a list of all currently connected users. If there is a zone file, it also shows where they are.")
+			fmt.Println()
+			fmt.Println("Usage: players [--format TEMPLATE]")
 			fmt.Println()
-			fmt.Println("Usage: players")
+			fmt.Println("Options:")
+			fmt.Println("    --format TEMPLATE  Print one line per player with a Go template, e.g. '{{.Name}} {{.ID}} {{.DinoClass}}'")
+			fmt.Println()
+			fmt.Println("Fields: ID, Name, DinoClass, Growth, Health, Stamina, Hunger, Thirst, Location.X, Location.Y, Location.Z")
 		case "dm":
 			fmt.Println("The dm command sends a direct message to a single player.")
 			fmt.Println()
 			fmt.Println("Usage: dm PLAYER_NAME MESSAGE")
+			fmt.Println("       dm PLAYER_NAME @TEMPLATE [KEY=VALUE...]")
 			fmt.Println()
 			fmt.Println("Arguments")
 			fmt.Println("    PLAYER_NAME  The name of the recipient")
 			fmt.Println("    MESSAGE      The message you want to send")
+			fmt.Println("    TEMPLATE     The name of a message template, see \"help templates\". {player} and {class} are filled with the recipient.")
+			fmt.Println("    KEY=VALUE    Fills the placeholder {KEY} in the template")
+			fmt.Println()
+			fmt.Println("Like with announce, \\n starts a new line, long messages are split and <<EOF reads the message from several lines.")
 			fmt.Println()
 			fmt.Println("Example: Greet a player")
 			fmt.Println("    dm PlayerNameHere Hello!")
+			fmt.Println()
+			fmt.Println("Example: Remind a player of the rules")
+			fmt.Println("    dm PlayerNameHere @nocamp")
+		case "templates":
+			fmt.Println("The templates command lists the message templates from the config or shows what a template would look like.")
+			fmt.Println("Templates can contain placeholders like {minutes}. {server} is the name of the server and {class} is the class of the player in {player}.")
+			fmt.Println("Messages that start with @ but don't name a template are sent as typed. Start a message with @@ to send a single @ in front of a template name.")
+			fmt.Println()
+			fmt.Println("Usage: templates [TEMPLATE [KEY=VALUE...]]")
+			fmt.Println()
+			fmt.Println("Arguments:")
+			fmt.Println("    TEMPLATE   The template to preview")
+			fmt.Println("    KEY=VALUE  Fills the placeholder {KEY} in the template")
+			fmt.Println()
+			fmt.Println("Example: Preview the restart announcement")
+			fmt.Println("    templates restart minutes=10")
 		case "info":
 			fmt.Println("The info command shows all available information about a specific player, like class, health and position.")
+			fmt.Println("If there is a zone file, it also shows the zone and grid square that the player is in.")
+			fmt.Println("For players that are offline, it shows their ID and when they were last seen, if they are in the name cache.")
 			fmt.Println()
-			fmt.Println("Usage: info PLAYER_NAME")
+			fmt.Println("Usage: info PLAYER_NAME [--format TEMPLATE]")
 			fmt.Println()
 			fmt.Println("Arguments:")
 			fmt.Println("    PLAYER_NAME  The name of a player")
 			fmt.Println()
+			fmt.Println("Options:")
+			fmt.Println("    --format TEMPLATE  Print the player with a Go template instead. The fields are the same as for \"players\"")
+			fmt.Println()
 			fmt.Println("Example: Get information on the player \"PlayerNameHere\"")
 			fmt.Println("    info PlayerNameHere")
+		case "find":
+			fmt.Println("The find command searches for players whose names contain a text or are close to it, and shows their IDs and classes. The best matches come first.")
+			fmt.Println("Commands that need a player name suggest similar names when there is no player with the exact name.")
+			fmt.Println()
+			fmt.Println("Usage: find QUERY")
+			fmt.Println()
+			fmt.Println("Arguments:")
+			fmt.Println("    QUERY  A part of the name, typos are allowed")
+			fmt.Println()
+			fmt.Println("Example: Find the player \"[PACK] Rexy_2000\"")
+			fmt.Println("    find rexy")
 		case "classes":
 			fmt.Println("The classes command can do several things regarding the list of allowed classes on the server.")
 			fmt.Println()
@@ -102,6 +191,19 @@ func helpCommand(args []string) error {
 			fmt.Println()
 			fmt.Println("Example: Allow only hypsilophodons")
 			fmt.Println("    classes allow Hypsilophodon")
+		case "caps":
+			fmt.Println("The caps command limits how many players can play a class at the same time. When a class reaches its limit, it is removed from the allowed classes until a slot frees up. Classes that you disallowed with the classes command stay disallowed.")
+			fmt.Println("The server can't tell which classes are allowed, so you have to set them with \"classes allow\" before you can set a cap.")
+			fmt.Println()
+			fmt.Println("Usage: caps SUBCOMMAND [ARGUMENT...]")
+			fmt.Println()
+			fmt.Println("Subcommands:")
+			fmt.Println("    list    Shows all limits and how many players currently play each class")
+			fmt.Println("    set     Limits a class to a number of players. You have to provide the class and the limit. A limit of 0 keeps the class disallowed.")
+			fmt.Println("    remove  Removes the limit of a class")
+			fmt.Println()
+			fmt.Println("Example: Allow at most 3 Carnotaurus")
+			fmt.Println("    caps set Carnotaurus 3")
 		case "whitelist":
 			fmt.Println("The whitelist command lets you manage the whitelist on the server.")
 			fmt.Println()
@@ -113,7 +215,7 @@ func helpCommand(args []string) error {
 			fmt.Println("    add     Adds one or more players to the whitelist")
 			fmt.Println("    remove  Removes one or more players from the whitelist")
 			fmt.Println()
-			fmt.Println("The add and remove commands will try to resolve player names to IDs for you. If the player that you want to add/remove is not currently playing on the server, you have to use the ID directly.")
+			fmt.Println("The add and remove commands will try to resolve player names to IDs for you. Players that are not currently playing on the server are looked up in the name cache, which remembers every player that PteroPrompt has seen. If a player has never been seen, you have to use the ID directly.")
 			fmt.Println()
 			fmt.Println("Example: Add two players to the whitelist")
 			fmt.Println("    whitelist add FirstPlayer SecondPlayer")
@@ -128,17 +230,63 @@ func helpCommand(args []string) error {
 			fmt.Println()
 			fmt.Println("Example: Kick a player")
 			fmt.Println("    kick PlayerNameHere You have broken the law")
+		case "ban":
+			fmt.Println("The ban command bans a player from the server. Players that are offline are looked up in the name cache, which remembers every player that PteroPrompt has seen.")
+			fmt.Println()
+			fmt.Println("Usage: ban PLAYER_NAME [--minutes MINUTES] [REASON]")
+			fmt.Println()
+			fmt.Println("Arguments:")
+			fmt.Println("    PLAYER_NAME  Name or ID of the player you want to ban")
+			fmt.Println("    REASON       A message that will be shown to the player")
+			fmt.Println()
+			fmt.Println("Options:")
+			fmt.Println("    --minutes MINUTES  How long the ban lasts. By default, the ban is permanent")
+			fmt.Println()
+			fmt.Println("Example: Ban a player for a day")
+			fmt.Println("    ban PlayerNameHere --minutes 1440 Combat logging")
 		case "send":
 			fmt.Println("The send command enables you to send commands to the server that this tool doesn't support yet.")
 			fmt.Println()
-			fmt.Println("Usage: send CODE [ARGUMENT...]")
+			fmt.Println("Usage: send [--hex] [--timeout DURATION] CODE [ARGUMENT...]")
+			fmt.Println()
+			fmt.Println("Options:")
+			fmt.Println("    --hex                Show the response as a hexdump")
+			fmt.Println("    --timeout DURATION   Stop waiting for a response after DURATION, e.g. 500ms. The maximum is 5s")
 			fmt.Println()
 			fmt.Println("Arguments:")
-			fmt.Println("    CODE      The 2-digit hexadecimal code of the message type you want to send")
-			fmt.Println("    ARGUMENT  (optional) The arguments that you want to send with your command")
+			fmt.Println("    CODE      The 2-digit hexadecimal code of the message type you want to send or its name")
+			fmt.Println("    ARGUMENT  (optional) The arguments that you want to send with your command. They are separated by commas")
+			fmt.Println()
+			fmt.Println("Type \"opcodes\" to get a list of all known codes and their names.")
 			fmt.Println()
 			fmt.Println("Examples: Send an announcement")
 			fmt.Println("    send 10 Testing")
+			fmt.Println("    send announce Testing")
+			fmt.Println()
+			fmt.Println("Try an unknown code and look at the raw response")
+			fmt.Println("    send --hex --timeout 1s 7f")
+		case "probe":
+			fmt.Println("The probe command sends every opcode in a range to the server and records which ones get a response, time out or make the server drop the connection.")
+			fmt.Println("Known opcodes that change things on the server, like kick or wipe_corpses, are always skipped. Opcodes without a response take 5 seconds each.")
+			fmt.Println("If the server drops the connection, PteroPrompt reconnects and continues with the next opcode.")
+			fmt.Println("The probe always has to be confirmed in a terminal, even with --yes or -y.")
+			fmt.Println()
+			fmt.Println("Usage: probe [--params PARAMS] FROM TO [REPORT]")
+			fmt.Println()
+			fmt.Println("Options:")
+			fmt.Println("    --params PARAMS  Comma separated parameters that are sent with every opcode. By default, no parameters are sent")
+			fmt.Println()
+			fmt.Println("Arguments:")
+			fmt.Println("    FROM    The first opcode as a hexadecimal number")
+			fmt.Println("    TO      The last opcode as a hexadecimal number")
+			fmt.Println("    REPORT  (optional) The file that the report is written to. Defaults to probe-DATE-TIME.txt in the data directory")
+			fmt.Println()
+			fmt.Println("Example: Look for new commands between a0 and af")
+			fmt.Println("    probe a0 af")
+		case "opcodes":
+			fmt.Println("The opcodes command lists the message types of the Evrima RCON that are known to this tool. Their names can be used with the send command.")
+			fmt.Println()
+			fmt.Println("Usage: opcodes")
 		case "wipe_corspes":
 			fmt.Println("The wipe_corspes command removes all corpses from the map to improve performance.")
 			fmt.Println()
@@ -165,12 +313,111 @@ func helpCommand(args []string) error {
 			fmt.Println()
 			fmt.Println("Example: Disable boars")
 			fmt.Println("    ai disable Boar")
+		case "watch":
+			fmt.Println("The watch command shows events on the server as they happen. The server doesn't report these events by itself, so the player list is checked every few seconds.")
+			fmt.Println()
+			fmt.Println("Usage: watch SUBCOMMAND [on|off]")
+			fmt.Println()
+			fmt.Println("Subcommands:")
+			fmt.Println("    players  Shows a message whenever a player joins or leaves the server")
+			fmt.Println()
+			fmt.Println("Example: Stop showing players joining and leaving")
+			fmt.Println("    watch players off")
+		case "rules":
+			fmt.Println("The rules command controls the rule engine, which regularly checks all players against the rules in the config file. Players that break a rule are warned with a direct message and kicked if they keep breaking it.")
+			fmt.Println()
+			fmt.Println("Usage: rules SUBCOMMAND")
+			fmt.Println()
+			fmt.Println("Subcommands:")
+			fmt.Println("    list    Shows a list of all configured rules")
+			fmt.Println("    check   Shows who is breaking rules right now without doing anything about it")
+			fmt.Println("    start   Starts enforcing the rules")
+			fmt.Println("    stop    Stops enforcing the rules")
+			fmt.Println("    status  Shows whether the rules are currently enforced")
+			fmt.Println()
+			fmt.Println("Example: See who is breaking rules")
+			fmt.Println("    rules check")
+		case "seen":
+			fmt.Println("The seen command shows when a player was last seen on the server, which names they used and their most recent sessions. This only works if session tracking is turned on in the config file.")
+			fmt.Println()
+			fmt.Println("Usage: seen PLAYER_NAME|PLAYER_ID")
+			fmt.Println()
+			fmt.Println("Arguments:")
+			fmt.Println("    PLAYER_NAME  Any name the player has used")
+			fmt.Println("    PLAYER_ID    The ID of the player")
+			fmt.Println()
+			fmt.Println("Example: Find out when a player was last online")
+			fmt.Println("    seen PlayerNameHere")
+		case "playtime":
+			fmt.Println("The playtime command shows how long a player has played on the server in total. This only works if session tracking is turned on in the config file.")
+			fmt.Println()
+			fmt.Println("Usage: playtime PLAYER_ID [--since DURATION]")
+			fmt.Println()
+			fmt.Println("Arguments:")
+			fmt.Println("    PLAYER_ID  The ID or any name of the player")
+			fmt.Println("    DURATION   Only count the playtime in this time frame, e.g. 7d for 7 days or 12h for 12 hours")
+			fmt.Println()
+			fmt.Println("Example: Show the playtime of the last 30 days")
+			fmt.Println("    playtime 76561190000000000 --since 30d")
+		case "top":
+			fmt.Println("The top command shows the players with the most playtime. This only works if session tracking is turned on in the config file.")
+			fmt.Println()
+			fmt.Println("Usage: top playtime [--since DURATION]")
+			fmt.Println()
+			fmt.Println("Arguments:")
+			fmt.Println("    DURATION  Only count the playtime in this time frame, e.g. 7d for 7 days or 12h for 12 hours")
+			fmt.Println()
+			fmt.Println("Example: Show who played the most in the last week")
+			fmt.Println("    top playtime --since 7d")
+		case "audit":
+			fmt.Println("The audit command shows the most recent entries of the audit log. Every command that changes something on the server is recorded there, together with the time, the operator and the server.")
+			fmt.Println()
+			fmt.Println("Usage: audit [--since DURATION] [--operator NAME] [--action ACTION] [--player PLAYER_ID] [--limit N]")
+			fmt.Println()
+			fmt.Println("Options:")
+			fmt.Println("    --since     Only show entries in this time frame, e.g. 7d for 7 days or 12h for 12 hours")
+			fmt.Println("    --operator  Only show entries by this operator")
+			fmt.Println("    --action    Only show entries whose action contains this text, e.g. kick")
+			fmt.Println("    --player    Only show entries that concern the player with this ID")
+			fmt.Println("    --limit     Show at most N entries (default 20)")
+			fmt.Println()
+			fmt.Println("Example: Show all kicks of the last day")
+			fmt.Println("    audit --action kick --since 1d")
+		case "alias":
+			fmt.Println("The alias command gives a command a shorter name. Arguments after the alias are added to the command, or fill $1 to $9 and $* (all arguments) if the command uses them.")
+			fmt.Println("Aliases are saved in the config file.")
+			fmt.Println()
+			fmt.Println("Usage: alias [list]")
+			fmt.Println("       alias NAME = COMMAND")
+			fmt.Println("       alias remove NAME")
+			fmt.Println()
+			fmt.Println("Examples: Announce a restart with two letters")
+			fmt.Println("    alias rs = announce Restart soon")
+			fmt.Println("    rs")
+			fmt.Println()
+			fmt.Println("Example: Warn a player")
+			fmt.Println("    alias warn = dm $1 Warning: $*")
+		case "macro":
+			fmt.Println("The macro command defines commands that run several other commands one after another. Like with aliases, $1 to $9 and $* are replaced with the arguments.")
+			fmt.Println("If a step fails, the rest of the macro is skipped. Macros are saved in the config file.")
+			fmt.Println()
+			fmt.Println("Usage: macro list")
+			fmt.Println("       macro define NAME { COMMAND; COMMAND... }")
+			fmt.Println("       macro remove NAME")
+			fmt.Println()
+			fmt.Println("Example: Wipe corpses and tell everyone about it")
+			fmt.Println("    macro define cleanup { wipe_corpses; announce Corpses cleared }")
+			fmt.Println("    cleanup")
 		case "quit":
 			fmt.Println("The quit command exits this program.")
 			fmt.Println()
 			fmt.Println("Usage: quit")
 		default:
-			helpCommand([]string{})
+			if description, ok := macros.Describe(strings.ToLower(args[0])); ok {
+				fmt.Printf("%s runs %s\n", strings.ToLower(args[0]), description)
+				return nil
+			}
+			helpCommand(macros, []string{})
 		}
 	}
 	return nil
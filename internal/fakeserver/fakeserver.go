@@ -0,0 +1,368 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package fakeserver contains an in-process stand-in for the RCON server of
+// The Isle Evrima. It speaks the same protocol as the real server, so it can
+// be used to test everything that talks to a server without running the game.
+package fakeserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// Handler answers a single command. If ok is false, the server doesn't send
+// any response, which makes the client run into a timeout.
+type Handler func(command byte, params []string) (response string, ok bool)
+
+// Serve accepts connections on l until it is closed. Every client has to
+// authenticate with password before its commands are passed to handler.
+func Serve(l net.Listener, password string, handler Handler) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveConn(conn, password, handler)
+	}
+}
+
+func serveConn(conn net.Conn, password string, handler Handler) {
+	defer conn.Close()
+
+	authenticated := false
+	buf := make([]byte, 10*1024)
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil || n == 0 {
+			return
+		}
+		msg := buf[:n]
+
+		switch msg[0] {
+		case rcon.Auth:
+			if string(msg[1:]) == password {
+				authenticated = true
+				conn.Write([]byte("Password Accepted"))
+			} else {
+				conn.Write([]byte("Password Rejected"))
+			}
+		case rcon.ExecCommand:
+			if !authenticated || len(msg) < 2 {
+				return
+			}
+			var params []string
+			if len(msg) > 2 {
+				params = strings.Split(string(msg[2:]), ",")
+			}
+			response, ok := handler(msg[1], params)
+			if ok {
+				conn.Write([]byte(response))
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Message is a direct message or kick that the server received.
+type Message struct {
+	PlayerID string
+	Text     string
+}
+
+// Ban is a ban that the server received.
+type Ban struct {
+	Name     string
+	PlayerID string
+	Reason   string
+	Minutes  string
+}
+
+// Server is a fake Evrima server with a scriptable state. All fields may be
+// changed at any time, as long as the server is locked.
+type Server struct {
+	sync.Mutex
+
+	Details rcon.ServerDetails
+	Players []rcon.Player
+
+	Whitelist       []string
+	Playables       []rcon.DinoClass
+	DisabledAI      []rcon.AIClass
+	AIDensity       string
+	Announcements   []string
+	DirectMessages  []Message
+	Kicks           []Message
+	Bans            []Ban
+	CorpseWipes     int
+	RequestsHandled int
+
+	// Handlers replace the built-in behaviour for individual commands. They
+	// are called while the server is locked.
+	Handlers map[byte]Handler
+
+	listener *trackingListener
+	password string
+}
+
+// trackingListener remembers the connections that it accepted, so that they
+// can be closed to simulate network problems.
+type trackingListener struct {
+	net.Listener
+
+	mutex sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mutex.Lock()
+		l.conns = append(l.conns, conn)
+		l.mutex.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) closeConns() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+// Start starts a server on a random port on localhost.
+func Start(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	l := &trackingListener{Listener: listener}
+
+	s := &Server{
+		Details: rcon.ServerDetails{
+			Name:               "Fake Server",
+			Map:                "Gateway",
+			MaxPlayers:         100,
+			HasPassword:        true,
+			EnableGlobalChat:   true,
+			SpawnAI:            true,
+			DayLengthMinutes:   45,
+			NightLengthMinutes: 15,
+		},
+		Handlers: make(map[byte]Handler),
+		listener: l,
+		password: password,
+	}
+
+	go Serve(l, password, s.handle)
+
+	return s, nil
+}
+
+// Addr returns the address that clients can connect to.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Disconnect closes all connections, as if the server had crashed. Clients
+// can connect again right away.
+func (s *Server) Disconnect() {
+	s.listener.closeConns()
+}
+
+// SetPlayers replaces the list of players that are online.
+func (s *Server) SetPlayers(players ...rcon.Player) {
+	s.Lock()
+	defer s.Unlock()
+	s.Players = players
+}
+
+// AddPlayer lets a player join the server.
+func (s *Server) AddPlayer(player rcon.Player) {
+	s.Lock()
+	defer s.Unlock()
+	s.Players = append(s.Players, player)
+}
+
+// RemovePlayer lets a player leave the server.
+func (s *Server) RemovePlayer(playerID string) {
+	s.Lock()
+	defer s.Unlock()
+	s.Players = slices.DeleteFunc(s.Players, func(p rcon.Player) bool { return p.ID == playerID })
+}
+
+func (s *Server) handle(command byte, params []string) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.RequestsHandled++
+
+	if handler, ok := s.Handlers[command]; ok {
+		return handler(command, params)
+	}
+
+	switch command {
+	case rcon.Announce:
+		s.Announcements = append(s.Announcements, strings.Join(params, ","))
+		return "Announced", true
+	case rcon.DirectMessage:
+		if len(params) < 2 {
+			return "Invalid arguments", true
+		}
+		s.DirectMessages = append(s.DirectMessages, Message{params[0], strings.Join(params[1:], ",")})
+		return "Message sent", true
+	case rcon.GetServerDetails:
+		return s.serverDetails(), true
+	case rcon.WipeCorpses:
+		s.CorpseWipes++
+		return "Corpses wiped", true
+	case rcon.UpdatePlayables:
+		s.Playables = s.Playables[:0]
+		for _, param := range params {
+			s.Playables = append(s.Playables, rcon.DinoClass(param))
+		}
+		return "Playables updated", true
+	case rcon.KickPlayer:
+		if len(params) < 1 {
+			return "Invalid arguments", true
+		}
+		s.Kicks = append(s.Kicks, Message{params[0], strings.Join(params[1:], ",")})
+		s.Players = slices.DeleteFunc(s.Players, func(p rcon.Player) bool { return p.ID == params[0] })
+		return "Player kicked", true
+	case rcon.BanPlayer:
+		// Like the real server, commas in the reason shift the other
+		// parameters
+		if len(params) != 4 {
+			return "Invalid arguments", true
+		}
+		s.Bans = append(s.Bans, Ban{params[0], params[1], params[2], params[3]})
+		s.Players = slices.DeleteFunc(s.Players, func(p rcon.Player) bool { return p.ID == params[1] })
+		return "Player banned", true
+	case rcon.GetPlayerList:
+		return s.playerList(), true
+	case rcon.GetPlayerData:
+		return s.playerData(), true
+	case rcon.Save:
+		return "Saved", true
+	case rcon.ToggleWhitelist:
+		s.Details.Whitelist = !s.Details.Whitelist
+		return toggled("Whitelist", s.Details.Whitelist), true
+	case rcon.AddWhitelistID:
+		for _, id := range params {
+			if !slices.Contains(s.Whitelist, id) {
+				s.Whitelist = append(s.Whitelist, id)
+			}
+		}
+		return "Added to whitelist", true
+	case rcon.RemoveWhitelistID:
+		s.Whitelist = slices.DeleteFunc(s.Whitelist, func(id string) bool { return slices.Contains(params, id) })
+		return "Removed from whitelist", true
+	case rcon.ToggleGlobalChat:
+		s.Details.EnableGlobalChat = !s.Details.EnableGlobalChat
+		return toggled("Global chat", s.Details.EnableGlobalChat), true
+	case rcon.ToggleHumans:
+		s.Details.EnableHumans = !s.Details.EnableHumans
+		return toggled("Humans", s.Details.EnableHumans), true
+	case rcon.ToggleAI:
+		s.Details.SpawnAI = !s.Details.SpawnAI
+		return toggled("AI", s.Details.SpawnAI), true
+	case rcon.DisableAIClasses:
+		s.DisabledAI = s.DisabledAI[:0]
+		for _, param := range params {
+			s.DisabledAI = append(s.DisabledAI, rcon.AIClass(param))
+		}
+		return "AI classes updated", true
+	case rcon.SetAIDensity:
+		s.AIDensity = strings.Join(params, ",")
+		return "AI density updated", true
+	default:
+		// The real server doesn't answer unknown commands
+		return "", false
+	}
+}
+
+func toggled(name string, state bool) string {
+	if state {
+		return name + ": On"
+	}
+	return name + ": Off"
+}
+
+func (s *Server) serverDetails() string {
+	d := s.Details
+	// String values must not be last, because the client can't parse that
+	return fmt.Sprintf(
+		"ServerDetails\nServerName: %s, ServerPassword: %s, ServerMap: %s, ServerMaxPlayers: %d, ServerCurrentPlayers: %d, "+
+			"bEnableMutations: %t, bEnableHumans: %t, bServerPassword: %t, bQueueEnabled: %t, bServerWhitelist: %t, "+
+			"bSpawnAI: %t, bAllowRecordingReplay: %t, bUseRegionSpawning: %t, bUseRegionSpawnCooldown: %t, "+
+			"RegionSpawnCooldownTimeSeconds: %d, ServerDayLengthMinutes: %d, ServerNightLengthMinutes: %d, bEnableGlobalChat: %t",
+		d.Name, d.Password, d.Map, d.MaxPlayers, len(s.Players),
+		d.EnableMutations, d.EnableHumans, d.HasPassword, d.QueueEnabled, d.Whitelist,
+		d.SpawnAI, d.AllowRecordingGameplay, d.UseRegionSpawning, d.UseRegionSpawnCooldown,
+		d.RegionSpawnCooldownTimeSeconds, d.DayLengthMinutes, d.NightLengthMinutes, d.EnableGlobalChat,
+	)
+}
+
+func (s *Server) playerList() string {
+	var b strings.Builder
+	b.WriteString("PlayerList\n")
+	for _, p := range s.Players {
+		fmt.Fprintf(&b, "%s,%s,,", p.ID, p.Name)
+	}
+	return b.String()
+}
+
+func (s *Server) playerData() string {
+	// The client truncates percentages, so they are sent with an extra half
+	// percent to survive the round trip.
+	percent := func(v int8) string {
+		return fmt.Sprintf("%.4f", (float64(v)+0.5)/100)
+	}
+
+	var b strings.Builder
+	b.WriteString("PlayerData\n")
+	for _, p := range s.Players {
+		class := p.DinoClass
+		if class == "" {
+			class = rcon.Hypsilophodon
+		}
+		fmt.Fprintf(&b,
+			"Name: %s, PlayerID: %s, Location: X=%.3f Y=%.3f Z=%.3f, Class: BP_%s_C, Growth: %s, Health: %s, Stamina: %s, Hunger: %s, Thirst: %s\n",
+			p.Name, p.ID, p.Location.X, p.Location.Y, p.Location.Z, class,
+			percent(p.Growth), percent(p.Health), percent(p.Stamina), percent(p.Hunger), percent(p.Thirst),
+		)
+	}
+	return b.String()
+}
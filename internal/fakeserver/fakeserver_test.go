@@ -0,0 +1,194 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package fakeserver
+
+import (
+	"errors"
+	"testing"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func connect(t *testing.T, s *Server, password string) (*rcon.Client, error) {
+	t.Helper()
+	client, err := rcon.Connect(s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, client.Auth(password)
+}
+
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := Start("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestAuth(t *testing.T) {
+	s := startServer(t)
+
+	if _, err := connect(t, s, "secret"); err != nil {
+		t.Errorf("correct password: %v", err)
+	}
+	if _, err := connect(t, s, "wrong"); !errors.Is(err, rcon.ErrIncorrectPassword) {
+		t.Errorf("wrong password: got %v, want %v", err, rcon.ErrIncorrectPassword)
+	}
+}
+
+func TestPlayers(t *testing.T) {
+	s := startServer(t)
+	want := []rcon.Player{
+		{
+			ID:        "76561198000000001",
+			Name:      "Alice",
+			Location:  rcon.Location{X: -1234.5, Y: 6789.25, Z: 100},
+			DinoClass: rcon.Carnotaurus,
+			Growth:    75, Health: 100, Stamina: 42, Hunger: 0, Thirst: 13,
+		},
+		{ID: "76561198000000002", Name: "Bob", DinoClass: rcon.Stegosaurus, Growth: 50},
+	}
+	s.SetPlayers(want...)
+
+	client, err := connect(t, s, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := client.GetPlayerList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(want) {
+		t.Fatalf("got %d players, want %d", len(list), len(want))
+	}
+	for i := range want {
+		if list[i].ID != want[i].ID || list[i].Name != want[i].Name {
+			t.Errorf("player %d: got %s/%s, want %s/%s", i, list[i].ID, list[i].Name, want[i].ID, want[i].Name)
+		}
+	}
+
+	data, err := client.GetPlayerData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != len(want) {
+		t.Fatalf("got %d players, want %d", len(data), len(want))
+	}
+	for i := range want {
+		if data[i] != want[i] {
+			t.Errorf("player %d: got %+v, want %+v", i, data[i], want[i])
+		}
+	}
+}
+
+func TestServerDetails(t *testing.T) {
+	s := startServer(t)
+	s.AddPlayer(rcon.Player{ID: "1", Name: "Alice"})
+
+	client, err := connect(t, s, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := client.GetServerDetails()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Name != "Fake Server" || details.Map != "Gateway" {
+		t.Errorf("got name %q and map %q", details.Name, details.Map)
+	}
+	if details.CurrentPlayers != 1 || details.MaxPlayers != 100 {
+		t.Errorf("got %d/%d players, want 1/100", details.CurrentPlayers, details.MaxPlayers)
+	}
+	if !details.SpawnAI || !details.EnableGlobalChat || details.Whitelist {
+		t.Errorf("unexpected toggles: %+v", details)
+	}
+}
+
+func TestToggles(t *testing.T) {
+	s := startServer(t)
+	client, err := connect(t, s, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	on, err := client.ToggleWhitelist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !on || !s.Details.Whitelist {
+		t.Errorf("whitelist should be on after the first toggle")
+	}
+
+	on, err = client.ToggleWhitelist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if on || s.Details.Whitelist {
+		t.Errorf("whitelist should be off after the second toggle")
+	}
+
+	on, err = client.ToggleAI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if on {
+		t.Errorf("AI should be off after the toggle")
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	s := startServer(t)
+	s.Handlers[0x99] = func(command byte, params []string) (string, bool) {
+		return "echo " + params[0], true
+	}
+
+	client, err := connect(t, s, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := client.ExecCommand(0x99, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != "echo hello" {
+		t.Errorf("got %q, want %q", response, "echo hello")
+	}
+
+	if err := client.Announce("Restart in 10 minutes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendDirectMessage("1", "Hello, there"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if len(s.Announcements) != 1 || s.Announcements[0] != "Restart in 10 minutes" {
+		t.Errorf("got announcements %q", s.Announcements)
+	}
+	if len(s.DirectMessages) != 1 || s.DirectMessages[0] != (Message{"1", "Hello, there"}) {
+		t.Errorf("got direct messages %+v", s.DirectMessages)
+	}
+}
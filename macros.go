@@ -0,0 +1,282 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxMacroDepth limits how deeply aliases and macros may use each other, so
+// that one that uses itself doesn't run forever.
+const maxMacroDepth = 10
+
+var macroNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+var macroParamPattern = regexp.MustCompile(`\$(\d|\*)`)
+
+// Macros holds the aliases and macros that the user defined. They are saved
+// in the config file.
+type Macros struct {
+	config *Config
+}
+
+func NewMacros(config *Config) *Macros {
+	if config.Aliases == nil {
+		config.Aliases = make(map[string]string)
+	}
+	if config.Macros == nil {
+		config.Macros = make(map[string][]string)
+	}
+	return &Macros{config}
+}
+
+// Expand returns the lines that command stands for. ok is false if command
+// is neither an alias nor a macro.
+//
+// $1 to $9 in the definition are replaced with the arguments and $* with all
+// of them. Arguments that an alias doesn't use are appended to it.
+func (m *Macros) Expand(command string, args []string) (lines []string, ok bool) {
+	if alias, ok := m.config.Aliases[command]; ok {
+		line, used := expandMacroParams(alias, args)
+		if !used && len(args) > 0 {
+			line += " " + strings.Join(args, " ")
+		}
+		return []string{line}, true
+	}
+
+	if steps, ok := m.config.Macros[command]; ok {
+		for _, step := range steps {
+			line, _ := expandMacroParams(step, args)
+			lines = append(lines, line)
+		}
+		return lines, true
+	}
+
+	return nil, false
+}
+
+// expandMacroParams fills the positional parameters in line. used is true if
+// line has any.
+func expandMacroParams(line string, args []string) (expanded string, used bool) {
+	expanded = macroParamPattern.ReplaceAllStringFunc(line, func(param string) string {
+		used = true
+		if param == "$*" {
+			return strings.Join(args, " ")
+		}
+		n, _ := strconv.Atoi(param[1:])
+		if n < 1 || n > len(args) {
+			return ""
+		}
+		return args[n-1]
+	})
+	return expanded, used
+}
+
+// Names returns the names of all aliases and macros in alphabetical order.
+func (m *Macros) Names() []string {
+	names := slices.Collect(maps.Keys(m.config.Aliases))
+	names = slices.AppendSeq(names, maps.Keys(m.config.Macros))
+	slices.Sort(names)
+	return names
+}
+
+// Describe returns what an alias or macro does.
+func (m *Macros) Describe(name string) (string, bool) {
+	if alias, ok := m.config.Aliases[name]; ok {
+		return alias, true
+	}
+	if steps, ok := m.config.Macros[name]; ok {
+		return "{ " + strings.Join(steps, "; ") + " }", true
+	}
+	return "", false
+}
+
+// checkMacroName reports to the user if name can't be used for an alias or macro.
+func checkMacroName(name string) bool {
+	if !macroNamePattern.MatchString(name) {
+		fmt.Printf("Invalid name \"%s\". Names must start with a letter and may only contain letters, digits, - and _.\n", name)
+		return false
+	}
+	if slices.Contains(commandNames, name) {
+		fmt.Printf("\"%s\" is already a command\n", name)
+		return false
+	}
+	return true
+}
+
+func (m *Macros) save() error {
+	if err := m.config.Update("aliases", m.config.Aliases); err != nil {
+		return err
+	}
+	return m.config.Update("macros", m.config.Macros)
+}
+
+// unquoteDefinition removes the quotes around the last argument of a command
+// in an alias or macro, like the message in `announce "Restart soon"`.
+// Commands take the rest of the line as it is, so the quotes would end up in
+// the message otherwise.
+func unquoteDefinition(command string) string {
+	for i := 0; i < len(command); i++ {
+		if (command[i] == '"' || command[i] == '\'') && (i == 0 || command[i-1] == ' ') {
+			return command[:i] + trimQuotes(command[i:])
+		}
+	}
+	return command
+}
+
+func aliasCommand(macros *Macros, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		if len(macros.config.Aliases) == 0 {
+			fmt.Println("There are no aliases")
+			return nil
+		}
+		names := slices.Sorted(maps.Keys(macros.config.Aliases))
+		width := 0
+		for _, name := range names {
+			width = max(width, len(name))
+		}
+		for _, name := range names {
+			fmt.Printf("%-*s = %s\n", width, name, macros.config.Aliases[name])
+		}
+		return nil
+	}
+
+	if len(args) >= 2 && args[1] == "=" {
+		name := strings.ToLower(args[0])
+		if !checkMacroName(name) {
+			return nil
+		}
+		if _, ok := macros.config.Macros[name]; ok {
+			fmt.Printf("\"%s\" is already a macro\n", name)
+			return nil
+		}
+		definition := unquoteDefinition(trimQuotes(strings.TrimSpace(strings.Join(args[2:], " "))))
+		if definition == "" {
+			fmt.Println("Missing COMMAND")
+			return nil
+		}
+		macros.config.Aliases[name] = definition
+		if err := macros.save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s = %s\n", name, definition)
+		return nil
+	}
+
+	if args[0] == "remove" {
+		if len(args) < 2 {
+			fmt.Println("Missing NAME")
+			return nil
+		}
+		name := strings.ToLower(args[1])
+		if _, ok := macros.config.Aliases[name]; !ok {
+			fmt.Printf("There is no alias \"%s\"\n", name)
+			return nil
+		}
+		delete(macros.config.Aliases, name)
+		if err := macros.save(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed alias %s\n", name)
+		return nil
+	}
+
+	fmt.Println("Usage: alias NAME = COMMAND")
+	fmt.Println("Type \"help alias\" to learn more about this command.")
+	return nil
+}
+
+func macroCommand(macros *Macros, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("No subcommand provided")
+		fmt.Println("Type \"help macro\" to learn more about this command.")
+		return nil
+	}
+
+	switch cmd := strings.ToLower(args[0]); cmd {
+	case "list":
+		if len(macros.config.Macros) == 0 {
+			fmt.Println("There are no macros")
+			return nil
+		}
+		for _, name := range slices.Sorted(maps.Keys(macros.config.Macros)) {
+			description, _ := macros.Describe(name)
+			fmt.Printf("%s %s\n", name, description)
+		}
+	case "define":
+		if len(args) < 2 {
+			fmt.Println("Missing NAME")
+			return nil
+		}
+		name := strings.ToLower(args[1])
+		if !checkMacroName(name) {
+			return nil
+		}
+		if _, ok := macros.config.Aliases[name]; ok {
+			fmt.Printf("\"%s\" is already an alias\n", name)
+			return nil
+		}
+
+		body := strings.TrimSpace(strings.Join(args[2:], " "))
+		if !strings.HasPrefix(body, "{") || !strings.HasSuffix(body, "}") {
+			fmt.Println("The steps of a macro must be in braces, e.g. macro define NAME { COMMAND; COMMAND }")
+			return nil
+		}
+		var steps []string
+		for _, step := range strings.Split(body[1:len(body)-1], ";") {
+			if step = strings.TrimSpace(step); step != "" {
+				steps = append(steps, unquoteDefinition(step))
+			}
+		}
+		if len(steps) == 0 {
+			fmt.Println("Missing COMMAND")
+			return nil
+		}
+
+		macros.config.Macros[name] = steps
+		if err := macros.save(); err != nil {
+			return err
+		}
+		description, _ := macros.Describe(name)
+		fmt.Printf("%s %s\n", name, description)
+	case "remove":
+		if len(args) < 2 {
+			fmt.Println("Missing NAME")
+			return nil
+		}
+		name := strings.ToLower(args[1])
+		if _, ok := macros.config.Macros[name]; !ok {
+			fmt.Printf("There is no macro \"%s\"\n", name)
+			return nil
+		}
+		delete(macros.config.Macros, name)
+		if err := macros.save(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed macro %s\n", name)
+	default:
+		fmt.Printf("Invalid subcommand \"%s\".\n", cmd)
+		fmt.Println("Type \"help macro\" to learn more about this command.")
+	}
+
+	return nil
+}
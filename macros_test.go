@@ -0,0 +1,150 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	execute(t, repl, `alias rs = announce "Restart soon"`)
+	execute(t, repl, "rs")
+	execute(t, repl, "alias warn = dm $1 Warning")
+	execute(t, repl, "warn Bob")
+	execute(t, repl, "alias say = announce")
+	execute(t, repl, "say Hello everyone")
+
+	// Quotes are only removed from definitions, not from what people type
+	execute(t, repl, `announce "Quoted"`)
+
+	server.Lock()
+	defer server.Unlock()
+	if want := []string{"Restart soon", "Hello everyone", `"Quoted"`}; !slices.Equal(server.Announcements, want) {
+		t.Errorf("announcements = %q, want %q", server.Announcements, want)
+	}
+	if len(server.DirectMessages) != 1 || server.DirectMessages[0].PlayerID != bob.ID || server.DirectMessages[0].Text != "Warning" {
+		t.Errorf("direct messages = %v", server.DirectMessages)
+	}
+}
+
+func TestMacro(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	execute(t, repl, `macro define cleanup { wipe_corpses; announce "Corpses cleared by $1" }`)
+	execute(t, repl, "cleanup Alice")
+
+	server.Lock()
+	defer server.Unlock()
+	if server.CorpseWipes != 1 {
+		t.Errorf("corpse wipes = %d, want 1", server.CorpseWipes)
+	}
+	if want := []string{"Corpses cleared by Alice"}; !slices.Equal(server.Announcements, want) {
+		t.Errorf("announcements = %q, want %q", server.Announcements, want)
+	}
+}
+
+func TestMacroPermissions(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionReadOnly)
+
+	output := execute(t, repl, "macro define cleanup { wipe_corpses }")
+	if !strings.Contains(output, "not allowed") {
+		t.Errorf("got %q, want a permission error", output)
+	}
+
+	// A moderator defined the macro, but it can't do more than the user
+	// that runs it
+	captureOutput(t, func() {
+		macroCommand(repl.macros, strings.Split("define cleanup { wipe_corpses }", " "))
+	})
+	output = execute(t, repl, "cleanup")
+	if !strings.Contains(output, "not allowed") {
+		t.Errorf("got %q, want a permission error", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if server.CorpseWipes != 0 {
+		t.Errorf("corpse wipes = %d, want 0", server.CorpseWipes)
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"alias kick = players", `"kick" is already a command`},
+		{"alias 1x = players", `Invalid name "1x"`},
+		{"macro define broken wipe_corpses", "must be in braces"},
+		{"macro remove nothing", `There is no macro "nothing"`},
+		{"macro define loop { loop }", "loop { loop }"},
+		{"loop", "Does it use itself?"},
+	}
+	for _, test := range tests {
+		if output := execute(t, repl, test.line); !strings.Contains(output, test.want) {
+			t.Errorf("%s: got %q, want %q", test.line, output, test.want)
+		}
+	}
+}
+
+func TestMacrosAreSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"data_dir": "/tmp/data"}`), 0644)
+
+	config, err := LoadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	macros := NewMacros(config)
+	captureOutput(t, func() {
+		aliasCommand(macros, strings.Split("rs = announce Restart soon", " "))
+		macroCommand(macros, strings.Split("define cleanup { wipe_corpses; announce Done }", " "))
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]any
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved["data_dir"] != "/tmp/data" {
+		t.Errorf("data_dir = %v, other settings must be kept", saved["data_dir"])
+	}
+
+	config, err = LoadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Aliases["rs"] != "announce Restart soon" {
+		t.Errorf("aliases = %v", config.Aliases)
+	}
+	if want := []string{"wipe_corpses", "announce Done"}; !slices.Equal(config.Macros["cleanup"], want) {
+		t.Errorf("macros = %v, want cleanup = %q", config.Macros, want)
+	}
+}
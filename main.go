@@ -21,8 +21,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	rcon "github.com/butt4cak3/theislercon"
 	"github.com/chzyer/readline"
@@ -32,15 +34,82 @@ var ErrPlayerNotFound = errors.New("player not found")
 
 func main() {
 	quiet := false
+	eventLogPath := ""
+	configPath := ""
+	profileName := ""
+	readOnly := false
+	yes := false
+	dryRun := false
+	recordPath := ""
+	replayPath := ""
+	listenAddress := defaultExporterAddress
 
 	serverAddress := os.Getenv("PTEROPROMPT_RCON_ADDRESS")
 	rconPassword := os.Getenv("PTEROPROMPT_RCON_PASSWORD")
 
+	args := os.Args[1:]
+
+	// Modes replace the REPL with something else
+	mode := ""
+	if len(args) > 0 && (args[0] == "top" || args[0] == "exporter") {
+		mode = args[0]
+		args = args[1:]
+	}
+
 	argID := 0
-	for _, arg := range os.Args[1:] {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch arg {
 		case "-q":
 			quiet = true
+		case "-l":
+			if i+1 >= len(args) {
+				printHelp(os.Args[0])
+				os.Exit(1)
+			}
+			i++
+			eventLogPath = args[i]
+		case "-c":
+			if i+1 >= len(args) {
+				printHelp(os.Args[0])
+				os.Exit(1)
+			}
+			i++
+			configPath = args[i]
+		case "-p":
+			if i+1 >= len(args) {
+				printHelp(os.Args[0])
+				os.Exit(1)
+			}
+			i++
+			profileName = args[i]
+		case "--read-only":
+			readOnly = true
+		case "-y", "--yes":
+			yes = true
+		case "--dry-run":
+			dryRun = true
+		case "--record":
+			if i+1 >= len(args) {
+				printHelp(os.Args[0])
+				os.Exit(1)
+			}
+			i++
+			recordPath = args[i]
+		case "--listen":
+			if i+1 >= len(args) {
+				printHelp(os.Args[0])
+				os.Exit(1)
+			}
+			i++
+			listenAddress = args[i]
+		case "--replay":
+			if i+1 >= len(args) {
+				printHelp(os.Args[0])
+				os.Exit(1)
+			}
+			i++
+			replayPath = args[i]
 		case "-h":
 			printHelp(os.Args[0])
 			return
@@ -61,8 +130,85 @@ func main() {
 		}
 	}
 
+	var config *Config
 	var err error
 
+	if configPath != "" {
+		config, err = LoadConfig(configPath, true)
+	} else {
+		config, err = LoadConfig(DefaultConfigPath(), false)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	permission, err := ParsePermission(config.Permission)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(1)
+	}
+
+	var zones *ZoneMap
+	if config.ZonesFile != "" {
+		zones, err = LoadZoneMap(config.RelativePath(config.ZonesFile))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot load zone file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	operator := config.Audit.Operator
+
+	if profileName != "" {
+		profile, ok := config.Profiles[profileName]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown profile \"%s\"\n", profileName)
+			os.Exit(1)
+		}
+		// Addresses and passwords on the command line take precedence
+		if argID < 1 && profile.Address != "" {
+			serverAddress = profile.Address
+		}
+		if argID < 2 && profile.Password != "" {
+			rconPassword = profile.Password
+		}
+		if profile.Permission != "" {
+			permission, err = ParsePermission(profile.Permission)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid profile \"%s\": %v\n", profileName, err)
+				os.Exit(1)
+			}
+		}
+		if profile.Operator != "" {
+			operator = profile.Operator
+		}
+	}
+
+	if readOnly {
+		permission = PermissionReadOnly
+	}
+
+	if replayPath != "" {
+		exchanges, err := LoadRecording(replayPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot load recording: %v\n", err)
+			os.Exit(1)
+		}
+		if rconPassword == "" {
+			rconPassword = "replay"
+		}
+		replay, err := StartReplay(exchanges, rconPassword)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot start replay server: %v\n", err)
+			os.Exit(1)
+		}
+		defer replay.Close()
+		serverAddress = replay.Addr().String()
+		if !quiet {
+			fmt.Printf("Replaying %d commands from %s\n", len(exchanges), replayPath)
+		}
+	}
+
 	for serverAddress == "" {
 		serverAddress, err = readline.Line("Server address: ")
 		if err != nil {
@@ -81,21 +227,129 @@ func main() {
 		rconPassword = strings.TrimSpace(string(pwBytes))
 	}
 
-	client, err := rcon.Connect(serverAddress)
+	// The client connects to the recorder, which passes everything on to the
+	// server
+	connectAddress := serverAddress
+	if recordPath != "" {
+		recordFile, err := os.Create(recordPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create recording: %v\n", err)
+			os.Exit(1)
+		}
+		defer recordFile.Close()
+		recorder, err := StartRecorder(serverAddress, recordFile, func(err error) {
+			fmt.Fprintln(os.Stderr, err)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot start recording: %v\n", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		connectAddress = recorder.Addr()
+	}
+
+	rconClient, err := DialReconnecting(connectAddress, rconPassword)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "cannot connect to %s: %v\n", serverAddress, err)
+		if errors.Is(err, rcon.ErrIncorrectPassword) {
+			fmt.Fprintf(os.Stderr, "cannot authenticate with %s: %v\n", serverAddress, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "cannot connect to %s: %v\n", serverAddress, err)
+		}
+		os.Exit(1)
+	}
+	defer rconClient.Close()
+
+	var client Client = rconClient
+
+	auditPath := config.Audit.Path
+	if auditPath == "" {
+		auditPath, err = config.DataPath("audit.jsonl")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create data directory: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	auditLog := NewAuditLog(auditPath, operator, serverAddress)
+
+	if mode == "exporter" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", NewExporter(client))
+		if !quiet {
+			fmt.Printf("Serving metrics for %s on %s/metrics\n", serverAddress, listenAddress)
+		}
+		server := &http.Server{
+			Addr:              listenAddress,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		err = server.ListenAndServe()
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	defer client.Close()
 
-	err = client.Auth(rconPassword)
+	if mode == "top" {
+		// The dashboard draws the whole screen, so dry runs are only shown
+		// with the output of the action that caused them
+		wrapDashboardClient := func(command string) Client {
+			c := auditLog.Client(client, command)
+			if dryRun {
+				c = NewDryRunClient(c, os.Stdout)
+			}
+			return c
+		}
+
+		dashboard := NewDashboard(client, wrapDashboardClient, permission, config.Messages, zones, os.Stdout)
+		auditLog.OnError(func(err error) {
+			dashboard.Log(fmt.Sprintf("Warning: %v", err))
+		})
+
+		watcher := NewPlayerWatcher(client, dashboardInterval)
+		watcher.Subscribe(dashboard.Handle)
+		watcher.Start()
+		defer watcher.Stop()
+
+		err = dashboard.Run(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Every player that is seen is remembered, so that commands can find
+	// them when they are offline
+	nameCachePath, err := config.DataPath("names.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create data directory: %v\n", err)
+		os.Exit(1)
+	}
+	nameCache, err := LoadNameCache(nameCachePath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "cannot authenticate with %s: %v\n", serverAddress, err)
+		fmt.Fprintf(os.Stderr, "cannot load name cache: %v\n", err)
 		os.Exit(1)
 	}
+	defer nameCache.Save()
+	client = nameCache.Client(client)
+
+	if zones != nil && zones.Map != "" {
+		if details, err := client.GetServerDetails(); err == nil && !strings.EqualFold(details.Map, zones.Map) {
+			fmt.Fprintf(os.Stderr, "warning: the zone file is for %s, but the server runs %s\n", zones.Map, details.Map)
+		}
+	}
+
+	// Dry runs don't leave any traces that would change the next real run
+	config.Caps.DryRun = dryRun
+	config.Greeter.DryRun = dryRun
 
 	if !quiet {
-		fmt.Printf("Connected to %s. Type \"help\" to get a list of available commands.\n", serverAddress)
+		if dryRun {
+			fmt.Println("Dry run: Nothing will be changed on the server.")
+		}
+		if permission == PermissionAdmin {
+			fmt.Printf("Connected to %s. Type \"help\" to get a list of available commands.\n", serverAddress)
+		} else {
+			fmt.Printf("Connected to %s as %s. Type \"help\" to get a list of available commands.\n", serverAddress, permission)
+		}
 	}
 
 	rl, err := readline.New("> ")
@@ -105,93 +359,290 @@ func main() {
 	}
 	defer rl.Close()
 
-Repl:
+	auditLog.OnError(func(err error) {
+		fmt.Fprintf(rl.Stderr(), "warning: %v\n", err)
+	})
+
+	// wrapClient returns the client that is used for everything that is
+	// caused by command.
+	wrapClient := func(command string) Client {
+		c := auditLog.Client(client, command)
+		if dryRun {
+			c = NewDryRunClient(c, rl.Stdout())
+		}
+		return c
+	}
+
+	// Nobody can answer questions if the commands come from a script
+	interactive := readline.IsTerminal(int(os.Stdin.Fd()))
+	confirmer := NewConfirmer(rl, config.Confirm, yes || !interactive)
+
+	watcher := NewPlayerWatcher(client, defaultPollInterval)
+	watcher.OnError(func(err error) {
+		fmt.Fprintf(rl.Stderr(), "cannot poll player list: %v\n", err)
+	})
+
+	eventPrinter := NewEventPrinter(rl.Stdout())
+	watcher.Subscribe(eventPrinter.Handle)
+
+	if eventLogPath != "" {
+		eventLogFile, err := os.OpenFile(eventLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot open event log: %v\n", err)
+			os.Exit(1)
+		}
+		defer eventLogFile.Close()
+		watcher.Subscribe(NewEventLog(eventLogFile).Handle)
+		watcher.Start()
+	}
+	defer watcher.Stop()
+
+	// Read-only operators must not change anything on the server, not even
+	// automatically
+	automate := permission > PermissionReadOnly
+
+	if automate && config.Greeter.Enabled {
+		greeterPath, err := config.DataPath("greeter.json")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create data directory: %v\n", err)
+			os.Exit(1)
+		}
+		greeter, err := NewGreeter(wrapClient("greeter"), config.Greeter, greeterPath, func(err error) {
+			fmt.Fprintf(rl.Stderr(), "cannot greet player: %v\n", err)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot load greeter state: %v\n", err)
+			os.Exit(1)
+		}
+		watcher.Subscribe(greeter.Handle)
+		watcher.Start()
+	}
+
+	ruleEngine, err := NewRuleEngine(wrapClient("rules"), config.Rules, zones, func(message string) {
+		fmt.Fprintf(rl.Stdout(), "[%s] %s\n", time.Now().Format(time.TimeOnly), message)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid rules: %v\n", err)
+		os.Exit(1)
+	}
+	ruleEngine.OnError(func(err error) {
+		fmt.Fprintf(rl.Stderr(), "cannot enforce rules: %v\n", err)
+	})
+	if automate && config.Rules.Enabled {
+		ruleEngine.Start()
+	}
+	defer ruleEngine.Stop()
+
+	capsPath, err := config.DataPath("caps.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create data directory: %v\n", err)
+		os.Exit(1)
+	}
+	capWatcher, err := NewCapWatcher(wrapClient("caps"), config.Caps, capsPath, func(message string) {
+		fmt.Fprintf(rl.Stdout(), "[%s] %s\n", time.Now().Format(time.TimeOnly), message)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load class caps: %v\n", err)
+		os.Exit(1)
+	}
+	capWatcher.OnError(func(err error) {
+		fmt.Fprintf(rl.Stderr(), "cannot update class caps: %v\n", err)
+	})
+	if automate && len(capWatcher.Caps()) > 0 {
+		capWatcher.Start()
+	}
+	defer capWatcher.Stop()
+
+	var sessionStore *SessionStore
+	if config.Sessions.Enabled {
+		sessionsPath, err := config.DataPath("sessions.db")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create data directory: %v\n", err)
+			os.Exit(1)
+		}
+		sessionStore, err = OpenSessionStore(sessionsPath, nameCache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot open session database: %v\n", err)
+			os.Exit(1)
+		}
+		defer sessionStore.Close()
+
+		sessionTracker := NewSessionTracker(client, sessionStore, defaultPollInterval, func(err error) {
+			fmt.Fprintf(rl.Stderr(), "cannot record session: %v\n", err)
+		})
+		sessionTracker.OnError(func(err error) {
+			fmt.Fprintf(rl.Stderr(), "cannot record session: %v\n", err)
+		})
+		watcher.Subscribe(sessionTracker.Handle)
+		watcher.Start()
+		sessionTracker.Start()
+		defer sessionTracker.Stop()
+	}
+
+	if len(config.Webhooks) > 0 {
+		queuePath, err := config.DataPath("webhooks.json")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create data directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Webhooks are easier to read with the name of the server
+		serverName := serverAddress
+		if details, err := client.GetServerDetails(); err == nil {
+			serverName = details.Name
+		}
+
+		names := func(playerID string) string {
+			for _, player := range watcher.Players() {
+				if player.ID == playerID {
+					return player.Name
+				}
+			}
+			return ""
+		}
+		notifier, err := NewNotifier(config.Webhooks, serverName, queuePath, names, func(message string) {
+			fmt.Fprintf(rl.Stdout(), "[%s] %s\n", time.Now().Format(time.TimeOnly), message)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid webhooks: %v\n", err)
+			os.Exit(1)
+		}
+		watcher.Subscribe(notifier.HandlePlayerEvent)
+		watcher.Start()
+		auditLog.Subscribe(notifier.HandleAuditEntry)
+		ruleEngine.OnViolation(notifier.HandleViolation)
+		rconClient.OnConnectionChange(notifier.HandleConnection)
+		notifier.Start()
+		defer notifier.Stop()
+	}
+
+	if automate && config.Bridge.Enabled {
+		bridge := NewBridge(wrapClient("bridge"), config.Bridge, config.Messages, func(message string) {
+			fmt.Fprintf(rl.Stdout(), "[%s] %s\n", time.Now().Format(time.TimeOnly), message)
+		})
+		bridge.OnError(func(err error) {
+			fmt.Fprintf(rl.Stderr(), "cannot forward message: %v\n", err)
+		})
+		if err := bridge.Listen(); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot start bridge: %v\n", err)
+			os.Exit(1)
+		}
+		defer bridge.Close()
+		bridge.Start()
+		defer bridge.Stop()
+	}
+
+	repl := &Repl{
+		permission:   permission,
+		wrapClient:   wrapClient,
+		messages:     config.Messages,
+		confirmer:    confirmer,
+		auditLog:     auditLog,
+		watcher:      watcher,
+		eventPrinter: eventPrinter,
+		ruleEngine:   ruleEngine,
+		capWatcher:   capWatcher,
+		sessionStore: sessionStore,
+		macros:       NewMacros(config),
+		nameCache:    nameCache,
+		zones:        zones,
+		dataPath:     config.DataPath,
+		reconnect:    rconClient.Reset,
+	}
+
 	for {
 		line, err := rl.Readline()
 		if err != nil {
 			if err == io.EOF {
-				break Repl
+				break
 			}
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		line = strings.TrimSpace(line)
-
-		parts := strings.Split(line, " ")
-		if len(parts) == 0 {
-			continue
-		}
-
-		command := strings.ToLower(parts[0])
-		args := parts[1:]
-
-		switch command {
-		case "help":
-			err = helpCommand(args)
-		case "status":
-			err = statusCommand(client)
-		case "announce":
-			err = announceCommand(client, args)
-		case "players":
-			err = playerListCommand(client)
-		case "dm":
-			err = messageCommand(client, args)
-		case "info":
-			err = infoCommand(client, args)
-		case "classes":
-			err = classesCommand(client, args)
-		case "whitelist":
-			err = whitelistCommand(client, args)
-		case "kick":
-			err = kickCommand(client, args)
-		case "wipe_corpses":
-			err = wipeCorpsesCommand(client)
-		case "toggle_gc":
-			err = toggleGlobalChatCommand(client)
-		case "toggle_humans":
-			err = toggleHumansCommand(client)
-		case "ai":
-			err = aiCommand(client, args)
-		case "send":
-			err = customCommand(client, args)
-		case "quit":
-			break Repl
-		default:
-			fmt.Printf("Unknown command %s. Type \"help\" for a list of commands.\n", command)
-			continue
+
+		line, err = ReadHeredoc(line, func() (string, error) {
+			rl.SetPrompt("... ")
+			defer rl.SetPrompt("> ")
+			return rl.Readline()
+		})
+		if err != nil {
+			if err == io.EOF {
+				fmt.Fprintln(os.Stderr, "missing end of multi-line input")
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(1)
 		}
 
+		quit, err := repl.Execute(line)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s command failed: %v\n", command, err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
+		if quit {
+			break
+		}
 	}
 }
 
 func printHelp(programName string) {
-	fmt.Printf("Usage: %s [-h] [-q] [ ADDRESS [PASSWORD] ]\n", programName)
+	fmt.Printf("Usage: %s [MODE] [-h] [-q] [-y] [--read-only] [--dry-run] [--record FILE] [--replay FILE] [--listen ADDR] [-c FILE] [-p PROFILE] [-l FILE] [ ADDRESS [PASSWORD] ]\n", programName)
 	fmt.Println()
 	fmt.Println("Options:")
-	fmt.Println("    -h  Show this message")
-	fmt.Println("    -q  Print only command outputs")
+	fmt.Println("    -h             Show this message")
+	fmt.Println("    -q             Print only command outputs")
+	fmt.Println("    -y, --yes      Don't ask for confirmation before changing things on the server")
+	fmt.Println("    --read-only    Only allow commands that don't change anything on the server")
+	fmt.Println("    --dry-run      Print everything that would change the server instead of sending it")
+	fmt.Println("    --record FILE  Write every command and response to FILE")
+	fmt.Println("    --replay FILE  Answer commands from a recording instead of connecting to a server")
+	fmt.Println("    --listen ADDR  Address that the exporter listens on, defaults to :9105")
+	fmt.Println("    -c FILE        Read the config from FILE")
+	fmt.Println("    -p PROFILE     Connect with the address, password and permissions of a profile from the config")
+	fmt.Println("    -l FILE        Watch for players joining and leaving and append these events to FILE")
+	fmt.Println()
+	fmt.Println("Modes:")
+	fmt.Println("    top       Show a dashboard of the server and its players instead of the prompt")
+	fmt.Println("    exporter  Serve metrics about the server for Prometheus")
 	fmt.Println()
 	fmt.Println("Arguments:")
 	fmt.Println("    ADDRESS   Server address and port (optional)")
 	fmt.Println("    PASSWORD  RCON password (optional)")
 }
 
-// ResolvePlayerName turns a name into an ID.
-func ResolvePlayerName(client *rcon.Client, playerName string) (string, error) {
+// ResolvePlayerName turns a name or the ID of a player that is online into
+// an ID. Names don't have to match exactly, see normalizeName, but an exact
+// match wins. If several players match, the error is ErrAmbiguousName.
+func ResolvePlayerName(client Client, playerName string) (string, error) {
 	players, err := client.GetPlayerList()
 	if err != nil {
 		return "", err
 	}
 
+	matches := matchingPlayers(players, playerName)
+	switch len(matches) {
+	case 0:
+		return "", ErrPlayerNotFound
+	case 1:
+		return matches[0].ID, nil
+	default:
+		return "", ErrAmbiguousName
+	}
+}
+
+// matchingPlayers returns the players that ResolvePlayerName chooses from.
+func matchingPlayers(players []rcon.Player, playerName string) []rcon.Player {
+	var exact, normalized []rcon.Player
 	for _, player := range players {
-		if player.Name == playerName {
-			return player.ID, nil
+		switch {
+		case player.Name == playerName || player.ID == playerName:
+			exact = append(exact, player)
+		case normalizeName(player.Name) == normalizeName(playerName):
+			normalized = append(normalized, player)
 		}
 	}
-
-	return "", ErrPlayerNotFound
+	if len(exact) > 0 {
+		return exact
+	}
+	return normalized
 }
@@ -0,0 +1,137 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxMessageLength is how many characters fit into a single announcement or
+// direct message without being cut off in the game.
+const maxMessageLength = 200
+
+type MessagesConfig struct {
+	// MaxLength is how many characters are sent in a single message. Longer
+	// messages are split into several parts. Defaults to 200.
+	MaxLength int `json:"max_length"`
+
+	// DelaySeconds is how long to wait between the parts of a long message,
+	// so that players have time to read them. Defaults to 3.
+	DelaySeconds float64 `json:"delay_seconds"`
+
+	// Templates are messages that are used often. They are sent with
+	// "announce @NAME" or "dm PLAYER @NAME".
+	Templates map[string]string `json:"templates"`
+}
+
+func (c MessagesConfig) maxLength() int {
+	if c.MaxLength <= 0 {
+		return maxMessageLength
+	}
+	return c.MaxLength
+}
+
+func (c MessagesConfig) delay() time.Duration {
+	return time.Duration(c.DelaySeconds * float64(time.Second))
+}
+
+// Split splits a message into the parts that are sent to the server.
+func (c MessagesConfig) Split(text string) []string {
+	return splitMessage(text, c.maxLength())
+}
+
+// Send sends every part of a message with send, waiting between the parts.
+func (c MessagesConfig) Send(text string, send func(part string) error) error {
+	for i, part := range c.Split(text) {
+		if i > 0 {
+			time.Sleep(c.delay())
+		}
+		if err := send(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unescapeMessage replaces \n in text that the user typed with a line break.
+// A backslash can be typed as \\.
+func unescapeMessage(text string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped && r == 'n':
+			b.WriteRune('\n')
+		case escaped && r == '\\':
+			b.WriteRune('\\')
+		case escaped:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			continue
+		default:
+			b.WriteRune(r)
+		}
+		escaped = false
+	}
+	if escaped {
+		b.WriteRune('\\')
+	}
+	return b.String()
+}
+
+// splitMessage splits text into parts that are at most max characters long.
+// Parts end at spaces or line breaks if possible, words that are longer than
+// max are cut.
+func splitMessage(text string, max int) []string {
+	var parts []string
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > max {
+		// Keep lines together if possible, otherwise break at the last space
+		// that still fits
+		end := lastIndexFunc(runes[:max+1], func(r rune) bool { return r == '\n' })
+		if end <= 0 {
+			end = lastIndexFunc(runes[:max+1], unicode.IsSpace)
+		}
+		if end <= 0 {
+			end = max
+		}
+
+		part := strings.TrimSpace(string(runes[:end]))
+		if part != "" {
+			parts = append(parts, part)
+		}
+		runes = []rune(strings.TrimLeftFunc(string(runes[end:]), unicode.IsSpace))
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+func lastIndexFunc(runes []rune, f func(rune) bool) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if f(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want []string
+	}{
+		{"Hello world", 20, []string{"Hello world"}},
+		{"The server restarts soon", 12, []string{"The server", "restarts", "soon"}},
+		{"Supercalifragilistic", 8, []string{"Supercal", "ifragili", "stic"}},
+		{"First line\nSecond line", 16, []string{"First line", "Second line"}},
+		{"A\nB C D", 6, []string{"A", "B C D"}},
+		{"A\nB\nC D E", 6, []string{"A\nB", "C D E"}},
+		{"   ", 10, nil},
+	}
+	for _, test := range tests {
+		got := splitMessage(test.text, test.max)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("splitMessage(%q, %d) = %q, want %q", test.text, test.max, got, test.want)
+		}
+	}
+}
+
+func TestUnescapeMessage(t *testing.T) {
+	tests := map[string]string{
+		`Hello\nworld`:    "Hello\nworld",
+		`C:\\path`:        `C:\path`,
+		`not \\n a break`: `not \n a break`,
+		`50\% off`:        `50\% off`,
+		`trailing\`:       `trailing\`,
+	}
+	for text, want := range tests {
+		if got := unescapeMessage(text); got != want {
+			t.Errorf("unescapeMessage(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestReadHeredoc(t *testing.T) {
+	lines := []string{"Rule 1", "Rule 2", "EOF", "not read"}
+	readLine := func() (string, error) {
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+
+	got, err := ReadHeredoc("announce <<EOF", readLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "announce Rule 1\nRule 2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, _ = ReadHeredoc("announce 1 << 2", readLine)
+	if got != "announce 1 << 2" {
+		t.Errorf("got %q, line without a delimiter was changed", got)
+	}
+
+	if _, err := ReadHeredoc("dm Alice <<END", readLine); !errors.Is(err, io.EOF) {
+		t.Errorf("got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestAnnounceSplitsLongMessages(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	repl.messages = MessagesConfig{MaxLength: 10}
+
+	execute(t, repl, `announce Restart in\nten minutes`)
+
+	server.Lock()
+	defer server.Unlock()
+	want := []string{"Restart in", "ten", "minutes"}
+	if !slices.Equal(server.Announcements, want) {
+		t.Errorf("announcements = %q, want %q", server.Announcements, want)
+	}
+}
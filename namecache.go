@@ -0,0 +1,258 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// nameCacheSaveInterval is how often the cache is written while only the
+// last seen times change. New names are saved right away.
+const nameCacheSaveInterval = time.Minute
+
+// ErrAmbiguousName means that several players have the same name.
+// ErrAmbiguousCachedName is the same for players that are offline, so the
+// names come from the name cache instead of the server.
+var (
+	ErrAmbiguousName       = errors.New("several players had this name")
+	ErrAmbiguousCachedName = fmt.Errorf("%w in the name cache", ErrAmbiguousName)
+)
+
+// NameCacheEntry is a name that a player was seen with.
+type NameCacheEntry struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// NameCache remembers the names and IDs of every player that was ever seen,
+// so that commands can find players that are offline.
+type NameCache struct {
+	path string
+
+	mutex   sync.Mutex
+	entries map[string]*NameCacheEntry
+	changed bool
+	saved   time.Time
+}
+
+// LoadNameCache reads the cache from the file at path.
+func LoadNameCache(path string) (*NameCache, error) {
+	c := &NameCache{
+		path:    path,
+		entries: make(map[string]*NameCacheEntry),
+		saved:   time.Now(),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	var entries []*NameCacheEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		c.entries[nameCacheKey(entry.ID, entry.Name)] = entry
+	}
+
+	return c, nil
+}
+
+func nameCacheKey(id, name string) string {
+	return id + "\x00" + name
+}
+
+// Observe records that players are online right now.
+func (c *NameCache) Observe(players []rcon.Player, now time.Time) error {
+	c.mutex.Lock()
+	added := false
+	for _, player := range players {
+		if player.ID == "" || player.Name == "" {
+			continue
+		}
+		key := nameCacheKey(player.ID, player.Name)
+		entry, ok := c.entries[key]
+		if !ok {
+			entry = &NameCacheEntry{ID: player.ID, Name: player.Name, FirstSeen: now}
+			c.entries[key] = entry
+			added = true
+		}
+		entry.LastSeen = now
+		c.changed = true
+	}
+	save := added || (c.changed && now.Sub(c.saved) >= nameCacheSaveInterval)
+	c.mutex.Unlock()
+
+	if save {
+		return c.Save()
+	}
+	return nil
+}
+
+// Save writes the cache to its file.
+func (c *NameCache) Save() error {
+	c.mutex.Lock()
+	if !c.changed {
+		c.mutex.Unlock()
+		return nil
+	}
+	entries := make([]NameCacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, *entry)
+	}
+	c.changed = false
+	c.saved = time.Now()
+	c.mutex.Unlock()
+
+	slices.SortFunc(entries, func(a, b NameCacheEntry) int {
+		return strings.Compare(nameCacheKey(a.ID, a.Name), nameCacheKey(b.ID, b.Name))
+	})
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// Lookup returns the players that were seen with name, most recently seen
+// first. Every ID appears only once. A nil cache knows no players.
+func (c *NameCache) Lookup(name string) []NameCacheEntry {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name = normalizeName(name)
+	var found []NameCacheEntry
+	for _, entry := range c.entries {
+		if normalizeName(entry.Name) == name {
+			found = append(found, *entry)
+		}
+	}
+	slices.SortFunc(found, func(a, b NameCacheEntry) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return slices.CompactFunc(found, func(a, b NameCacheEntry) bool {
+		return a.ID == b.ID
+	})
+}
+
+// Names returns the names that a player was seen with, most recently seen
+// first. A nil cache knows no players.
+func (c *NameCache) Names(id string) []NameCacheEntry {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var found []NameCacheEntry
+	for _, entry := range c.entries {
+		if entry.ID == id {
+			found = append(found, *entry)
+		}
+	}
+	slices.SortFunc(found, func(a, b NameCacheEntry) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return found
+}
+
+// Client returns a client that records every player that client sees.
+func (c *NameCache) Client(client Client) Client {
+	return &nameCachingClient{client, c}
+}
+
+type nameCachingClient struct {
+	Client
+	cache *NameCache
+}
+
+// The cache is only a fallback, so errors while saving it must not break
+// the commands that happen to fetch the player list.
+
+func (c *nameCachingClient) GetPlayerList() ([]rcon.Player, error) {
+	players, err := c.Client.GetPlayerList()
+	if err == nil {
+		c.cache.Observe(players, time.Now())
+	}
+	return players, err
+}
+
+func (c *nameCachingClient) GetPlayerData() ([]rcon.Player, error) {
+	players, err := c.Client.GetPlayerData()
+	if err == nil {
+		c.cache.Observe(players, time.Now())
+	}
+	return players, err
+}
+
+// resolvePlayer turns a name into an ID like ResolvePlayerName, but also
+// finds players that are offline in the cache. cached is nil if the player
+// is online.
+func resolvePlayer(client Client, cache *NameCache, name string) (id string, cached *NameCacheEntry, err error) {
+	id, err = ResolvePlayerName(client, name)
+	if !errors.Is(err, ErrPlayerNotFound) {
+		return id, nil, err
+	}
+
+	entries := cache.Lookup(name)
+	switch len(entries) {
+	case 0:
+		return "", nil, ErrPlayerNotFound
+	case 1:
+		return entries[0].ID, &entries[0], nil
+	default:
+		return "", nil, ErrAmbiguousCachedName
+	}
+}
+
+// printCached tells the user that a player was found in the cache instead of
+// on the server.
+func printCached(entry *NameCacheEntry) {
+	fmt.Printf("%s is offline, using the ID %s from the name cache (last seen %s, %s ago)\n",
+		entry.Name, entry.ID, entry.LastSeen.Format(time.DateTime), formatDuration(time.Since(entry.LastSeen)))
+}
+
+// printAmbiguous lists the players that were seen with name.
+func printAmbiguous(cache *NameCache, name string) {
+	fmt.Printf("Several players were called \"%s\". Use one of their IDs instead:\n", name)
+	for _, entry := range cache.Lookup(name) {
+		fmt.Printf("    %s  last seen %s\n", entry.ID, entry.LastSeen.Format(time.DateTime))
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+var carol = rcon.Player{ID: "76561198000000003", Name: "Carol", DinoClass: rcon.Dryosaurus}
+
+func TestNameCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names.json")
+	cache, err := LoadNameCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2026, 10, 12, 20, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(24 * time.Hour)
+	cache.Observe([]rcon.Player{alice, {ID: "76561198000000009", Name: "Rex"}}, monday)
+	cache.Observe([]rcon.Player{alice, {ID: "76561198000000010", Name: "Rex"}}, tuesday)
+
+	// New names are saved right away
+	cache, err = LoadNameCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := cache.Lookup("alice")
+	if len(entries) != 1 || entries[0].ID != alice.ID || !entries[0].FirstSeen.Equal(monday) || !entries[0].LastSeen.Equal(tuesday) {
+		t.Errorf("Lookup(alice) = %+v", entries)
+	}
+
+	entries = cache.Lookup("Rex")
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	if want := []string{"76561198000000010", "76561198000000009"}; !slices.Equal(ids, want) {
+		t.Errorf("Lookup(Rex) = %q, want %q", ids, want)
+	}
+
+	var nilCache *NameCache
+	if entries := nilCache.Lookup("alice"); entries != nil {
+		t.Errorf("a nil cache found %v", entries)
+	}
+}
+
+// newCachedTestRepl returns a REPL whose name cache knows carol, who is
+// offline.
+func newCachedTestRepl(t *testing.T) (*Repl, *fakeserver.Server) {
+	t.Helper()
+	repl, server := newTestRepl(t, PermissionAdmin)
+	cache, err := LoadNameCache(filepath.Join(t.TempDir(), "names.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Observe([]rcon.Player{alice, bob, carol}, time.Now().Add(-2*time.Hour))
+	repl.nameCache = cache
+	return repl, server
+}
+
+func TestWhitelistFromCache(t *testing.T) {
+	repl, server := newCachedTestRepl(t)
+
+	output := execute(t, repl, "whitelist add Carol Bob 76561198000000099")
+	if !strings.Contains(output, "Carol is offline, using the ID "+carol.ID+" from the name cache") {
+		t.Errorf("got %q, want a note about the cache", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if want := []string{carol.ID, bob.ID, "76561198000000099"}; !slices.Equal(server.Whitelist, want) {
+		t.Errorf("whitelist = %q, want %q", server.Whitelist, want)
+	}
+}
+
+func TestInfoFromCache(t *testing.T) {
+	repl, _ := newCachedTestRepl(t)
+
+	output := execute(t, repl, "info carol")
+	if !strings.Contains(output, "Player Carol is not online") || !strings.Contains(output, carol.ID) || !strings.Contains(output, "2h 0m ago") {
+		t.Errorf("got %q", output)
+	}
+}
+
+func TestBan(t *testing.T) {
+	repl, server := newCachedTestRepl(t)
+
+	execute(t, repl, "ban Alice --minutes 60 Camping, again")
+	output := execute(t, repl, "ban Carol")
+	if !strings.Contains(output, "from the name cache") {
+		t.Errorf("got %q, want a note about the cache", output)
+	}
+	output = execute(t, repl, "ban Nobody")
+	if !strings.Contains(output, `Player "Nobody" not found`) {
+		t.Errorf("got %q", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	want := []fakeserver.Ban{
+		{Name: "Alice", PlayerID: alice.ID, Reason: "Camping again", Minutes: "60"},
+		{Name: "Carol", PlayerID: carol.ID, Reason: "You were banned from the server.", Minutes: "0"},
+	}
+	if !slices.Equal(server.Bans, want) {
+		t.Errorf("bans = %+v, want %+v", server.Bans, want)
+	}
+	if slices.ContainsFunc(server.Players, func(p rcon.Player) bool { return p.ID == alice.ID }) {
+		t.Errorf("Alice is still online")
+	}
+}
+
+func TestAmbiguousCachedName(t *testing.T) {
+	repl, server := newCachedTestRepl(t)
+	repl.nameCache.Observe([]rcon.Player{{ID: "76561198000000004", Name: "Carol"}}, time.Now())
+
+	output := execute(t, repl, "ban Carol")
+	if !strings.Contains(output, "Several players were called \"Carol\"") {
+		t.Errorf("got %q", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Bans) != 0 {
+		t.Errorf("bans = %+v, want none", server.Bans)
+	}
+}
+
+func TestAmbiguousOnlineName(t *testing.T) {
+	repl, server := newCachedTestRepl(t)
+	server.AddPlayer(rcon.Player{ID: "76561198000000005", Name: "Ｒｅｘｙ"})
+	server.AddPlayer(rcon.Player{ID: "76561198000000006", Name: "REXY"})
+
+	// The players are online, so the list comes from the server
+	for _, line := range []string{"ban rexy", "whitelist add rexy"} {
+		output := execute(t, repl, line)
+		if !strings.Contains(output, "Several players are called \"rexy\"") || !strings.Contains(output, "76561198000000005  Ｒｅｘｙ") || !strings.Contains(output, "76561198000000006  REXY") {
+			t.Errorf("%s: got %q, want both players", line, output)
+		}
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Bans) != 0 || len(server.Whitelist) != 0 {
+		t.Errorf("bans = %+v, whitelist = %v, want neither", server.Bans, server.Whitelist)
+	}
+}
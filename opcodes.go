@@ -0,0 +1,78 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// Opcode is a known message type of the Evrima RCON.
+type Opcode struct {
+	Code        byte
+	Name        string
+	Params      string
+	Description string
+}
+
+var opcodes = []Opcode{
+	{rcon.Announce, "announce", "MESSAGE", "Sends a message to all players"},
+	{rcon.DirectMessage, "dm", "PLAYER_ID,MESSAGE", "Sends a message to a single player"},
+	{rcon.GetServerDetails, "details", "", "Returns the server settings"},
+	{rcon.WipeCorpses, "wipe_corpses", "", "Removes all corpses from the map"},
+	{rcon.UpdatePlayables, "playables", "CLASS,...", "Sets the classes that players may choose"},
+	{rcon.BanPlayer, "ban", "NAME,PLAYER_ID,REASON,MINUTES", "Bans a player"},
+	{rcon.KickPlayer, "kick", "PLAYER_ID,REASON", "Kicks a player from the server"},
+	{rcon.GetPlayerList, "players", "", "Returns the IDs and names of all players"},
+	{rcon.Save, "save", "", "Saves the game"},
+	{rcon.GetPlayerData, "player_data", "", "Returns the location, class and stats of all players"},
+	{rcon.ToggleWhitelist, "toggle_whitelist", "", "Turns the whitelist on or off"},
+	{rcon.AddWhitelistID, "whitelist_add", "PLAYER_ID,...", "Adds players to the whitelist"},
+	{rcon.RemoveWhitelistID, "whitelist_remove", "PLAYER_ID,...", "Removes players from the whitelist"},
+	{rcon.ToggleGlobalChat, "toggle_gc", "", "Turns the global chat on or off"},
+	{rcon.ToggleHumans, "toggle_humans", "", "Turns the humans feature on or off"},
+	{rcon.ToggleAI, "toggle_ai", "", "Turns AI spawning on or off"},
+	{rcon.DisableAIClasses, "disable_ai", "CLASS,...", "Sets the AI classes that don't spawn"},
+	{rcon.SetAIDensity, "ai_density", "DENSITY", "Sets the AI density"},
+}
+
+// ParseOpcode accepts the name of a known opcode or a hexadecimal number.
+func ParseOpcode(s string) (byte, bool) {
+	for _, op := range opcodes {
+		if strings.EqualFold(op.Name, s) {
+			return op.Code, true
+		}
+	}
+	code, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 8)
+	if err != nil {
+		return 0, false
+	}
+	return byte(code), true
+}
+
+// OpcodeName returns the name of a known opcode or an empty string.
+func OpcodeName(code byte) string {
+	for _, op := range opcodes {
+		if op.Code == code {
+			return op.Name
+		}
+	}
+	return ""
+}
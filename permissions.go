@@ -0,0 +1,107 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission decides which commands an operator may use. Anyone with the RCON
+// password can do everything on the server, so this only protects against
+// mistakes, not against people who want to break things.
+type Permission int
+
+const (
+	PermissionReadOnly Permission = iota
+	PermissionModerator
+	PermissionAdmin
+)
+
+func ParsePermission(s string) (Permission, error) {
+	switch strings.ToLower(s) {
+	case "read-only", "readonly":
+		return PermissionReadOnly, nil
+	case "moderator":
+		return PermissionModerator, nil
+	case "admin", "":
+		return PermissionAdmin, nil
+	default:
+		return 0, fmt.Errorf("unknown permission level \"%s\"", s)
+	}
+}
+
+func (p Permission) String() string {
+	switch p {
+	case PermissionReadOnly:
+		return "read-only"
+	case PermissionModerator:
+		return "moderator"
+	case PermissionAdmin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+// requiredPermission returns the permission that is needed to run a command.
+// Unknown commands need no permission, so that the dispatcher can tell the
+// user that they don't exist.
+func requiredPermission(command string, args []string) Permission {
+	subcommand := ""
+	if len(args) > 0 {
+		subcommand = strings.ToLower(args[0])
+	}
+
+	switch command {
+	case "announce", "dm", "kick", "ban", "wipe_corpses":
+		return PermissionModerator
+	case "whitelist":
+		switch subcommand {
+		case "add", "remove":
+			return PermissionModerator
+		case "toggle":
+			return PermissionAdmin
+		}
+	case "classes":
+		if subcommand == "allow" {
+			return PermissionAdmin
+		}
+	case "caps":
+		if subcommand == "set" || subcommand == "remove" {
+			return PermissionAdmin
+		}
+	case "rules":
+		if subcommand == "start" || subcommand == "stop" {
+			return PermissionAdmin
+		}
+	case "ai":
+		if subcommand != "list" {
+			return PermissionAdmin
+		}
+	case "alias", "macro":
+		// They change the config file, which everyone shares
+		if subcommand != "list" {
+			return PermissionModerator
+		}
+	case "toggle_gc", "toggle_humans", "send", "probe":
+		return PermissionAdmin
+	}
+
+	return PermissionReadOnly
+}
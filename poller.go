@@ -0,0 +1,107 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Poller calls a function in the background in a fixed interval.
+type Poller struct {
+	interval time.Duration
+	poll     func() error
+
+	mutex   sync.Mutex
+	onError func(error)
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewPoller(interval time.Duration, poll func() error) *Poller {
+	return &Poller{
+		interval: interval,
+		poll:     poll,
+	}
+}
+
+// OnError sets a function that is called whenever the poll function fails.
+func (p *Poller) OnError(handler func(error)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.onError = handler
+}
+
+// Start starts polling in the background. The first poll happens right away.
+// Calling Start on a poller that is already running does nothing.
+func (p *Poller) Start() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stop != nil {
+		return
+	}
+
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.run(p.stop, p.done)
+}
+
+// Stop stops polling and waits until a poll that is currently running has
+// finished.
+func (p *Poller) Stop() {
+	p.mutex.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (p *Poller) Running() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.stop != nil
+}
+
+func (p *Poller) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.poll(); err != nil {
+			p.mutex.Lock()
+			onError := p.onError
+			p.mutex.Unlock()
+			if onError != nil {
+				onError(err)
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
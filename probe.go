@@ -0,0 +1,146 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+const (
+	ProbeResponded = "response"
+	ProbeTimeout   = "timeout"
+	ProbeDropped   = "dropped"
+	ProbeSkipped   = "skipped"
+	ProbeError     = "error"
+)
+
+// probeDenyList contains the opcodes that change things on the server. They
+// are already known, so there is no reason to risk sending them with made up
+// arguments.
+var probeDenyList = map[byte]bool{
+	rcon.Announce:          true,
+	rcon.DirectMessage:     true,
+	rcon.WipeCorpses:       true,
+	rcon.UpdatePlayables:   true,
+	rcon.BanPlayer:         true,
+	rcon.KickPlayer:        true,
+	rcon.Save:              true,
+	rcon.ToggleWhitelist:   true,
+	rcon.AddWhitelistID:    true,
+	rcon.RemoveWhitelistID: true,
+	rcon.ToggleGlobalChat:  true,
+	rcon.ToggleHumans:      true,
+	rcon.ToggleAI:          true,
+	rcon.DisableAIClasses:  true,
+	rcon.SetAIDensity:      true,
+}
+
+// ProbeResult is what happened when an opcode was sent to the server.
+type ProbeResult struct {
+	Code     byte
+	Status   string
+	Response string
+	Duration time.Duration
+}
+
+// Probe sends every opcode from from to to to the server and reports what
+// happened through progress. Opcodes on the deny-list are skipped. If the
+// server drops the connection, the client reconnects for the next opcode.
+// After a timeout, reconnect is called to get a new connection, because the
+// response may still arrive. The probe stops at the first other error, for
+// example if reconnecting fails.
+func Probe(client Client, reconnect func(), from, to byte, params []string, progress func(ProbeResult)) []ProbeResult {
+	var results []ProbeResult
+
+	for code := int(from); code <= int(to); code++ {
+		result := ProbeResult{Code: byte(code)}
+
+		if probeDenyList[result.Code] {
+			result.Status = ProbeSkipped
+		} else {
+			start := time.Now()
+			response, err := client.ExecCommand(result.Code, params...)
+			result.Duration = time.Since(start)
+			result.Response = response
+
+			var ne net.Error
+			switch {
+			case err == nil:
+				result.Status = ProbeResponded
+			case errors.As(err, &ne) && ne.Timeout():
+				result.Status = ProbeTimeout
+				reconnect()
+			case isConnectionError(err):
+				result.Status = ProbeDropped
+			default:
+				result.Status = ProbeError
+				result.Response = err.Error()
+			}
+		}
+
+		results = append(results, result)
+		progress(result)
+
+		if result.Status == ProbeError {
+			break
+		}
+	}
+
+	return results
+}
+
+// String returns a single line that describes the result.
+func (r ProbeResult) String() string {
+	line := fmt.Sprintf("%02x  %-8s", r.Code, r.Status)
+	if name := OpcodeName(r.Code); name != "" {
+		line += fmt.Sprintf("  (%s)", name)
+	}
+	switch r.Status {
+	case ProbeResponded, ProbeError:
+		line += fmt.Sprintf("  %s  %q", r.Duration.Round(time.Millisecond), r.Response)
+	case ProbeTimeout:
+		line += fmt.Sprintf("  %s", r.Duration.Round(time.Millisecond))
+	}
+	return line
+}
+
+// WriteProbeReport writes one line per result and a summary.
+func WriteProbeReport(w io.Writer, results []ProbeResult, params []string, started time.Time) error {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+	}
+
+	fmt.Fprintf(w, "Probe started at %s with parameters %q\n", started.Format(time.RFC3339), params)
+	fmt.Fprintf(w, "%d responded, %d timed out, %d dropped the connection, %d skipped, %d errors\n",
+		counts[ProbeResponded], counts[ProbeTimeout], counts[ProbeDropped], counts[ProbeSkipped], counts[ProbeError])
+	fmt.Fprintln(w)
+
+	for _, r := range results {
+		if _, err := fmt.Fprintln(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ReconnectingClient is a client that connects to the server again when the
+// connection is lost. A call that fails because of a lost connection still
+// returns the error, because it's unknown whether the server received it. The
+// next call tries to reconnect.
+type ReconnectingClient struct {
+	address  string
+	password string
+
+	mutex    sync.Mutex
+	client   *rcon.Client
+	delay    time.Duration
+	retryAt  time.Time
+	lost     bool
+	onChange func(connected bool, err error)
+}
+
+// DialReconnecting connects to the server and authenticates. If that fails
+// the first time, it's most likely a typo, so the error is returned right
+// away.
+func DialReconnecting(address, password string) (*ReconnectingClient, error) {
+	c := &ReconnectingClient{address: address, password: password}
+	if _, err := c.conn(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// OnConnectionChange sets a function that is called when the connection is
+// lost and when it is restored.
+func (c *ReconnectingClient) OnConnectionChange(handler func(connected bool, err error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onChange = handler
+}
+
+func (c *ReconnectingClient) connect() (*rcon.Client, error) {
+	client, err := rcon.Connect(c.address)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Auth(c.password); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// conn returns the current connection or tries to open a new one.
+func (c *ReconnectingClient) conn() (*rcon.Client, error) {
+	c.mutex.Lock()
+
+	if c.client != nil {
+		defer c.mutex.Unlock()
+		return c.client, nil
+	}
+
+	if wait := time.Until(c.retryAt); wait > 0 {
+		defer c.mutex.Unlock()
+		return nil, fmt.Errorf("not connected, trying again in %s", wait.Round(time.Second))
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		c.delay = min(max(c.delay*2, minReconnectDelay), maxReconnectDelay)
+		c.retryAt = time.Now().Add(c.delay)
+		c.mutex.Unlock()
+		return nil, err
+	}
+
+	c.client = client
+	c.delay = 0
+	restored := c.lost
+	c.lost = false
+	onChange := c.onChange
+	c.mutex.Unlock()
+
+	if restored && onChange != nil {
+		onChange(true, nil)
+	}
+	return client, nil
+}
+
+// drop closes client if it is still the current connection.
+func (c *ReconnectingClient) drop(client *rcon.Client, err error) {
+	c.mutex.Lock()
+	if c.client != client {
+		c.mutex.Unlock()
+		return
+	}
+	c.client.Close()
+	c.client = nil
+	c.lost = true
+	onChange := c.onChange
+	c.mutex.Unlock()
+
+	if onChange != nil {
+		onChange(false, err)
+	}
+}
+
+func (c *ReconnectingClient) do(f func(client *rcon.Client) error) error {
+	client, err := c.conn()
+	if err != nil {
+		return err
+	}
+	err = f(client)
+	if isConnectionError(err) {
+		c.drop(client, err)
+	}
+	return err
+}
+
+// isConnectionError returns true if err means that the connection is gone.
+// Timeouts don't count, because the server doesn't respond to some commands
+// at all.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return false
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// Reset closes the current connection, so that the next call opens a new
+// one. This makes sure that a late response to a command that timed out
+// isn't taken for the response to the next command.
+func (c *ReconnectingClient) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+// Close closes the current connection. The client can't be used afterwards.
+func (c *ReconnectingClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.retryAt = time.Now().Add(100 * 365 * 24 * time.Hour)
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+func (c *ReconnectingClient) GetPlayerList() (players []rcon.Player, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		players, err = client.GetPlayerList()
+		return err
+	})
+	return players, err
+}
+
+func (c *ReconnectingClient) GetPlayerData() (players []rcon.Player, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		players, err = client.GetPlayerData()
+		return err
+	})
+	return players, err
+}
+
+func (c *ReconnectingClient) GetServerDetails() (details *rcon.ServerDetails, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		details, err = client.GetServerDetails()
+		return err
+	})
+	return details, err
+}
+
+func (c *ReconnectingClient) Announce(message string) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.Announce(message)
+	})
+}
+
+func (c *ReconnectingClient) SendDirectMessage(playerID, message string) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.SendDirectMessage(playerID, message)
+	})
+}
+
+func (c *ReconnectingClient) KickPlayer(playerID, reason string) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.KickPlayer(playerID, reason)
+	})
+}
+
+func (c *ReconnectingClient) WipeCorpses() error {
+	return c.do(func(client *rcon.Client) error {
+		return client.WipeCorpses()
+	})
+}
+
+func (c *ReconnectingClient) UpdatePlayables(classes []rcon.DinoClass) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.UpdatePlayables(classes)
+	})
+}
+
+func (c *ReconnectingClient) ToggleWhitelist() (state bool, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		state, err = client.ToggleWhitelist()
+		return err
+	})
+	return state, err
+}
+
+func (c *ReconnectingClient) AddWhitelistID(playerID ...string) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.AddWhitelistID(playerID...)
+	})
+}
+
+func (c *ReconnectingClient) RemoveWhitelistID(playerID ...string) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.RemoveWhitelistID(playerID...)
+	})
+}
+
+func (c *ReconnectingClient) ToggleGlobalChat() (state bool, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		state, err = client.ToggleGlobalChat()
+		return err
+	})
+	return state, err
+}
+
+func (c *ReconnectingClient) ToggleHumans() (state bool, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		state, err = client.ToggleHumans()
+		return err
+	})
+	return state, err
+}
+
+func (c *ReconnectingClient) ToggleAI() (state bool, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		state, err = client.ToggleAI()
+		return err
+	})
+	return state, err
+}
+
+func (c *ReconnectingClient) DisableAIClasses(classes []rcon.AIClass) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.DisableAIClasses(classes)
+	})
+}
+
+func (c *ReconnectingClient) SetAIDensity(density float32) error {
+	return c.do(func(client *rcon.Client) error {
+		return client.SetAIDensity(density)
+	})
+}
+
+func (c *ReconnectingClient) ExecCommand(command byte, params ...string) (response string, err error) {
+	err = c.do(func(client *rcon.Client) error {
+		response, err = client.ExecCommand(command, params...)
+		return err
+	})
+	return response, err
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func TestReconnectingClient(t *testing.T) {
+	server, err := fakeserver.Start("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.SetPlayers(alice)
+
+	if _, err := DialReconnecting(server.Addr(), "wrong"); !errors.Is(err, rcon.ErrIncorrectPassword) {
+		t.Errorf("got %v, want %v", err, rcon.ErrIncorrectPassword)
+	}
+
+	client, err := DialReconnecting(server.Addr(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var changes []bool
+	client.OnConnectionChange(func(connected bool, err error) {
+		changes = append(changes, connected)
+	})
+
+	if _, err := client.GetPlayerList(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The call that notices the lost connection fails, the next one
+	// reconnects
+	server.Disconnect()
+	if _, err := client.GetPlayerList(); err == nil {
+		t.Fatal("expected an error after the connection was lost")
+	}
+	players, err := client.GetPlayerList()
+	if err != nil {
+		t.Fatalf("expected to reconnect: %v", err)
+	}
+	if len(players) != 1 || players[0].ID != alice.ID {
+		t.Errorf("got %+v after reconnecting", players)
+	}
+	if !slices.Equal(changes, []bool{false, true}) {
+		t.Errorf("got connection changes %v, want [false true]", changes)
+	}
+
+	// A reset isn't a lost connection, the next call just uses a new one
+	client.Reset()
+	if _, err := client.GetPlayerList(); err != nil {
+		t.Fatalf("expected a new connection after a reset: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("got connection changes %v after a reset, want none", changes[2:])
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	server, err := fakeserver.Start("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := DialReconnecting(server.Addr(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	server.Close()
+	server.Disconnect()
+	client.GetPlayerList()
+
+	// The server is gone, so the first attempt fails and the second one
+	// waits for the backoff
+	if _, err := client.GetPlayerList(); err == nil {
+		t.Fatal("expected the reconnect to fail")
+	}
+	_, err = client.GetPlayerList()
+	if err == nil || err.Error() != "not connected, trying again in 1s" {
+		t.Errorf("got %v, want a backoff", err)
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+const (
+	RecordAuth     = "auth"
+	RecordCommand  = "command"
+	RecordResponse = "response"
+)
+
+// RecordEntry is a single line in a recording. Every message that the client
+// sends is followed by the responses of the server, if there are any. The
+// password is never recorded.
+//
+// Data holds the raw bytes, which are base64 encoded in the file, because
+// the server doesn't always send valid UTF-8. For the same reason, the
+// parameters of a command are stored in Data instead of Params if they
+// aren't valid UTF-8.
+type RecordEntry struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Opcode byte      `json:"opcode,omitempty"`
+	Params []string  `json:"params,omitempty"`
+	Data   []byte    `json:"data,omitempty"`
+}
+
+// Recorder is a proxy between the client and the server that writes
+// everything they say to each other to a file. The client library doesn't
+// expose the raw responses, so this is the only way to get them.
+type Recorder struct {
+	listener net.Listener
+	target   string
+	onError  func(error)
+
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	conns   sync.WaitGroup
+}
+
+// StartRecorder starts a proxy for the server at target on a random port on
+// localhost. Errors while writing the recording are passed to onError.
+func StartRecorder(target string, w io.Writer, onError func(error)) (*Recorder, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		listener: l,
+		target:   target,
+		onError:  onError,
+		encoder:  json.NewEncoder(w),
+	}
+	go r.serve()
+	return r, nil
+}
+
+// Addr returns the address that the client has to connect to.
+func (r *Recorder) Addr() string {
+	return r.listener.Addr().String()
+}
+
+// Close stops accepting connections and waits until the open ones are
+// closed.
+func (r *Recorder) Close() error {
+	err := r.listener.Close()
+	r.conns.Wait()
+	return err
+}
+
+func (r *Recorder) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		r.conns.Add(1)
+		go func() {
+			defer r.conns.Done()
+			r.proxy(conn)
+		}()
+	}
+}
+
+func (r *Recorder) write(entry RecordEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.encoder.Encode(entry); err != nil && r.onError != nil {
+		r.onError(fmt.Errorf("cannot write recording: %w", err))
+	}
+}
+
+func (r *Recorder) proxy(client net.Conn) {
+	defer client.Close()
+
+	server, err := net.Dial("tcp", r.target)
+	if err != nil {
+		if r.onError != nil {
+			r.onError(err)
+		}
+		return
+	}
+	defer server.Close()
+
+	responses := make(chan struct{})
+	go func() {
+		defer close(responses)
+		defer client.Close()
+		buf := make([]byte, 10*1024)
+		for {
+			n, err := server.Read(buf)
+			if err != nil {
+				return
+			}
+			r.write(RecordEntry{Time: time.Now(), Type: RecordResponse, Data: slices.Clone(buf[:n])})
+			if _, err := client.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 10*1024)
+	for {
+		n, err := client.Read(buf)
+		if err != nil {
+			break
+		}
+		r.write(requestEntry(buf[:n]))
+		if _, err := server.Write(buf[:n]); err != nil {
+			break
+		}
+	}
+
+	server.Close()
+	<-responses
+}
+
+func requestEntry(msg []byte) RecordEntry {
+	entry := RecordEntry{Time: time.Now()}
+	switch {
+	case msg[0] == rcon.Auth:
+		entry.Type = RecordAuth
+	case msg[0] == rcon.ExecCommand && len(msg) > 1:
+		entry.Type = RecordCommand
+		entry.Opcode = msg[1]
+		if len(msg) > 2 && utf8.Valid(msg[2:]) {
+			entry.Params = strings.Split(string(msg[2:]), ",")
+		} else if len(msg) > 2 {
+			entry.Data = slices.Clone(msg[2:])
+		}
+	default:
+		// Nothing that the client library sends, but it's still worth
+		// knowing about
+		entry.Type = RecordCommand
+		entry.Data = slices.Clone(msg)
+	}
+	return entry
+}
+
+// RecordedExchange is a command and everything the server responded with.
+type RecordedExchange struct {
+	Opcode   byte
+	Params   []string
+	Response string
+
+	// Answered is false if the server didn't respond at all.
+	Answered bool
+}
+
+// LoadRecording reads a recording and pairs every command with its response.
+func LoadRecording(path string) ([]RecordedExchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var exchanges []RecordedExchange
+	current := -1
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry RecordEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		switch entry.Type {
+		case RecordCommand:
+			params := entry.Params
+			if params == nil && entry.Data != nil {
+				params = strings.Split(string(entry.Data), ",")
+			}
+			exchanges = append(exchanges, RecordedExchange{Opcode: entry.Opcode, Params: params})
+			current = len(exchanges) - 1
+		case RecordResponse:
+			// A response can arrive in several pieces
+			if current >= 0 {
+				exchanges[current].Response += string(entry.Data)
+				exchanges[current].Answered = true
+			}
+		default:
+			current = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return exchanges, nil
+}
+
+// NewReplayHandler answers commands with the responses from a recording.
+// Every recorded exchange is used once, in the order in which they were
+// recorded. After that, the last response to the same command is repeated. If
+// the parameters of a command were never recorded, the last response to the
+// same opcode is used instead.
+func NewReplayHandler(exchanges []RecordedExchange) fakeserver.Handler {
+	var mutex sync.Mutex
+	used := make([]bool, len(exchanges))
+
+	respond := func(e RecordedExchange) (string, bool) {
+		return e.Response, e.Answered
+	}
+
+	return func(command byte, params []string) (string, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		lastSame, lastOpcode := -1, -1
+		for i, e := range exchanges {
+			if e.Opcode != command {
+				continue
+			}
+			lastOpcode = i
+			if !slices.Equal(e.Params, params) {
+				continue
+			}
+			lastSame = i
+			if !used[i] {
+				used[i] = true
+				return respond(e)
+			}
+		}
+
+		switch {
+		case lastSame >= 0:
+			return respond(exchanges[lastSame])
+		case lastOpcode >= 0:
+			return respond(exchanges[lastOpcode])
+		default:
+			return "", false
+		}
+	}
+}
+
+// StartReplay starts a stand-in server on a random port on localhost that
+// answers from a recording. It accepts password and nothing else.
+func StartReplay(exchanges []RecordedExchange, password string) (net.Listener, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go fakeserver.Serve(l, password, NewReplayHandler(exchanges))
+	return l, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server, err := fakeserver.Start("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.SetPlayers(alice, bob)
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	recorder, err := StartRecorder(server.Addr(), f, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := rcon.Connect(recorder.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Auth("secret"); err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := client.GetPlayerData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.KickPlayer(bob.ID, "Bye"); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	recorder.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "secret") {
+		t.Errorf("the password must not be recorded:\n%s", content)
+	}
+
+	exchanges, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 2 {
+		t.Fatalf("got %d exchanges, want 2: %+v", len(exchanges), exchanges)
+	}
+	if exchanges[1].Opcode != rcon.KickPlayer || !slices.Equal(exchanges[1].Params, []string{bob.ID, "Bye"}) {
+		t.Errorf("unexpected kick exchange %+v", exchanges[1])
+	}
+
+	replay, err := StartReplay(exchanges, "anything")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+
+	client, err = rcon.Connect(replay.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Auth("anything"); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := client.GetPlayerData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(replayed, recorded) {
+		t.Errorf("got %+v, want %+v", replayed, recorded)
+	}
+
+	// A different player gets the response of the closest recorded command
+	if err := client.KickPlayer(alice.ID, "Bye"); err != nil {
+		t.Errorf("kick: %v", err)
+	}
+}
+
+func TestRecordNonUTF8(t *testing.T) {
+	server, err := fakeserver.Start("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	response := "Caf\xe9 \xff\xfe"
+	server.Handlers = map[byte]fakeserver.Handler{
+		0x2f: func(command byte, params []string) (string, bool) { return response, true },
+	}
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	recorder, err := StartRecorder(server.Addr(), f, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := rcon.Connect(recorder.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Auth("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ExecCommand(0x2f, "M\xfcller"); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	recorder.Close()
+
+	exchanges, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 1 {
+		t.Fatalf("got %d exchanges, want 1: %+v", len(exchanges), exchanges)
+	}
+	if !slices.Equal(exchanges[0].Params, []string{"M\xfcller"}) {
+		t.Errorf("got params %q", exchanges[0].Params)
+	}
+	if exchanges[0].Response != response {
+		t.Errorf("got response %q, want %q", exchanges[0].Response, response)
+	}
+}
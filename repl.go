@@ -0,0 +1,216 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commandNames are the built-in commands. Aliases and macros can't use these
+// names.
+var commandNames = []string{
+	"help", "status", "announce", "players", "dm", "templates", "info", "find", "classes", "caps", "whitelist", "kick", "ban",
+	"wipe_corpses", "toggle_gc", "toggle_humans", "ai", "seen", "playtime", "top", "audit", "rules", "watch",
+	"send", "probe", "opcodes", "alias", "macro", "quit",
+}
+
+// Repl holds everything that the commands need and dispatches lines that the
+// user typed to them.
+type Repl struct {
+	permission Permission
+
+	// wrapClient returns the client that is used for everything that is
+	// caused by command.
+	wrapClient func(command string) Client
+
+	messages     MessagesConfig
+	confirmer    *Confirmer
+	auditLog     *AuditLog
+	watcher      *PlayerWatcher
+	eventPrinter *EventPrinter
+	ruleEngine   *RuleEngine
+	capWatcher   *CapWatcher
+	sessionStore *SessionStore
+	macros       *Macros
+	nameCache    *NameCache
+	zones        *ZoneMap
+
+	// dataPath returns the path of a file in the data directory.
+	dataPath func(name string) (string, error)
+
+	// reconnect makes the next command use a new connection.
+	reconnect func()
+
+	// depth is how many aliases and macros are currently being expanded.
+	depth int
+}
+
+// Execute runs a single line of input. It returns true if the user wants to
+// quit. Errors are only returned if something went wrong while talking to the
+// server, mistakes of the user are reported to them directly.
+func (r *Repl) Execute(line string) (quit bool, err error) {
+	line = strings.TrimSpace(line)
+
+	parts := strings.Split(line, " ")
+	if len(parts) == 0 {
+		return false, nil
+	}
+
+	command := strings.ToLower(parts[0])
+	args := parts[1:]
+
+	if lines, ok := r.macros.Expand(command, args); ok {
+		return r.executeMacro(command, lines)
+	}
+
+	if required := requiredPermission(command, args); r.permission < required {
+		fmt.Printf("You are not allowed to do that. This requires %s permissions, but you are connected as %s.\n", required, r.permission)
+		return false, nil
+	}
+
+	// "--yes" at the end of the line skips confirmations for this command.
+	// Anywhere else it may be part of a message.
+	yes := len(args) > 0 && args[len(args)-1] == "--yes"
+	if yes {
+		args = args[:len(args)-1]
+	}
+	confirm := r.confirmer.WithYes(yes)
+
+	// Everything the command does is recorded in the audit log
+	client := r.wrapClient(line)
+
+	switch command {
+	case "help":
+		err = helpCommand(r.macros, args)
+	case "status":
+		err = statusCommand(client, args)
+	case "announce":
+		err = announceCommand(client, r.messages, args)
+	case "players":
+		err = playerListCommand(client, r.zones, args)
+	case "dm":
+		err = messageCommand(client, r.messages, args)
+	case "templates":
+		err = templatesCommand(client, r.messages, args)
+	case "info":
+		err = infoCommand(client, r.nameCache, r.zones, args)
+	case "find":
+		err = findCommand(client, args)
+	case "classes":
+		err = classesCommand(client, r.capWatcher, confirm, args)
+	case "caps":
+		err = capsCommand(client, r.capWatcher, args)
+	case "whitelist":
+		err = whitelistCommand(client, r.nameCache, confirm, args)
+	case "kick":
+		err = kickCommand(client, args)
+	case "ban":
+		err = banCommand(client, r.nameCache, confirm, args)
+	case "wipe_corpses":
+		err = wipeCorpsesCommand(client, confirm)
+	case "toggle_gc":
+		err = toggleGlobalChatCommand(client)
+	case "toggle_humans":
+		err = toggleHumansCommand(client)
+	case "ai":
+		err = aiCommand(client, confirm, args)
+	case "seen":
+		err = seenCommand(r.sessionStore, args)
+	case "playtime":
+		err = playtimeCommand(r.sessionStore, args)
+	case "top":
+		err = topCommand(r.sessionStore, args)
+	case "audit":
+		err = auditCommand(r.auditLog, args)
+	case "rules":
+		err = rulesCommand(client, r.ruleEngine, args)
+	case "watch":
+		err = watchCommand(r.watcher, r.eventPrinter, args)
+	case "send":
+		err = customCommand(client, confirm, args)
+	case "probe":
+		err = probeCommand(client, r.reconnect, confirm, r.dataPath, args)
+	case "opcodes":
+		err = opcodesCommand()
+	case "alias":
+		err = aliasCommand(r.macros, args)
+	case "macro":
+		err = macroCommand(r.macros, args)
+	case "quit":
+		return true, nil
+	default:
+		fmt.Printf("Unknown command %s. Type \"help\" for a list of commands.\n", command)
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("%s command failed: %w", command, err)
+	}
+	return false, nil
+}
+
+// executeMacro runs the lines that an alias or macro stands for. It stops at
+// the first line that fails.
+func (r *Repl) executeMacro(name string, lines []string) (quit bool, err error) {
+	if r.depth >= maxMacroDepth {
+		fmt.Printf("%s uses too many other aliases or macros. Does it use itself?\n", name)
+		return false, nil
+	}
+
+	r.depth++
+	defer func() { r.depth-- }()
+
+	for _, line := range lines {
+		quit, err = r.Execute(line)
+		if quit || err != nil {
+			return quit, err
+		}
+	}
+	return false, nil
+}
+
+// ReadHeredoc lets the user type the last argument of a command over several
+// lines. If line ends with <<WORD, lines are read with readLine until one of
+// them is WORD, and are appended to the command with line breaks between
+// them. Other lines are returned as they are.
+func ReadHeredoc(line string, readLine func() (string, error)) (string, error) {
+	line = strings.TrimSpace(line)
+	i := strings.LastIndex(line, "<<")
+	if i < 0 || (i > 0 && line[i-1] != ' ') {
+		return line, nil
+	}
+	delimiter := line[i+2:]
+	if delimiter == "" || strings.ContainsAny(delimiter, " \t") {
+		return line, nil
+	}
+
+	var lines []string
+	for {
+		next, err := readLine()
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(next) == delimiter {
+			break
+		}
+		lines = append(lines, next)
+	}
+
+	return strings.TrimSpace(line[:i]) + " " + strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,418 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/butt4cak3/pteroprompt/internal/fakeserver"
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+var (
+	alice = rcon.Player{ID: "76561198000000001", Name: "Alice", DinoClass: rcon.Carnotaurus, Growth: 75, Health: 100}
+	bob   = rcon.Player{ID: "76561198000000002", Name: "Bob", DinoClass: rcon.Stegosaurus, Growth: 50, Health: 80}
+)
+
+// newTestRepl starts a fake server with Alice and Bob online and returns a
+// REPL that is connected to it. Confirmations are always answered with yes.
+func newTestRepl(t *testing.T, permission Permission) (*Repl, *fakeserver.Server) {
+	t.Helper()
+
+	server, err := fakeserver.Start("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	server.SetPlayers(alice, bob)
+
+	rconClient, err := DialReconnecting(server.Addr(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rconClient.Close() })
+
+	dir := t.TempDir()
+	auditLog := NewAuditLog(filepath.Join(dir, "audit.jsonl"), "tester", server.Addr())
+	wrapClient := func(command string) Client {
+		return auditLog.Client(rconClient, command)
+	}
+
+	ruleEngine, err := NewRuleEngine(wrapClient("rules"), RulesConfig{}, nil, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	capWatcher, err := NewCapWatcher(wrapClient("caps"), CapsConfig{}, filepath.Join(dir, "caps.json"), func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repl := &Repl{
+		permission:   permission,
+		wrapClient:   wrapClient,
+		confirmer:    NewConfirmer(nil, nil, true),
+		auditLog:     auditLog,
+		watcher:      NewPlayerWatcher(rconClient, time.Second),
+		eventPrinter: NewEventPrinter(io.Discard),
+		ruleEngine:   ruleEngine,
+		capWatcher:   capWatcher,
+		macros:       NewMacros(&Config{path: filepath.Join(dir, "config.json")}),
+		dataPath:     (&Config{DataDir: dir}).DataPath,
+		reconnect:    rconClient.Reset,
+	}
+	return repl, server
+}
+
+// captureOutput returns everything that f prints to stdout.
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- string(b)
+	}()
+
+	f()
+	w.Close()
+	return <-output
+}
+
+// execute runs line and fails the test if the command returns an error.
+func execute(t *testing.T, repl *Repl, line string) string {
+	t.Helper()
+	var err error
+	output := captureOutput(t, func() {
+		_, err = repl.Execute(line)
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+	return output
+}
+
+func TestPlayersCommand(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+
+	output := execute(t, repl, "players")
+	if !strings.Contains(output, "Alice") || !strings.Contains(output, "Bob") {
+		t.Errorf("expected both players in output:\n%s", output)
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+
+	output := execute(t, repl, "info alice")
+	for _, want := range []string{"Player Alice", alice.ID, "Carnotaurus", "Growth:   75%", "Health: 100%"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+
+	output = execute(t, repl, "info Carol")
+	if !strings.Contains(output, "not found") {
+		t.Errorf("expected unknown player to be reported:\n%s", output)
+	}
+}
+
+func TestKickCommand(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionModerator)
+
+	execute(t, repl, "kick Bob Too many Stegosaurs")
+
+	server.Lock()
+	defer server.Unlock()
+	want := []fakeserver.Message{{PlayerID: bob.ID, Text: "Too many Stegosaurs"}}
+	if !slices.Equal(server.Kicks, want) {
+		t.Errorf("got kicks %+v, want %+v", server.Kicks, want)
+	}
+}
+
+func TestWhitelistAddCommand(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionModerator)
+
+	// Names of players that are online are resolved, everything else is
+	// passed through as an ID
+	execute(t, repl, "whitelist add Alice 76561198000000003")
+
+	server.Lock()
+	defer server.Unlock()
+	want := []string{alice.ID, "76561198000000003"}
+	if !slices.Equal(server.Whitelist, want) {
+		t.Errorf("got whitelist %v, want %v", server.Whitelist, want)
+	}
+}
+
+func TestAnnounceCommand(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionModerator)
+
+	execute(t, repl, "announce Restart in 10 minutes")
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Announcements) != 1 || server.Announcements[0] != "Restart in 10 minutes" {
+		t.Errorf("got announcements %q", server.Announcements)
+	}
+}
+
+func TestClassesAllowCommand(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	execute(t, repl, "classes allow Carnotaurus Stegosaurus")
+
+	server.Lock()
+	playables := server.Playables
+	server.Unlock()
+	want := []rcon.DinoClass{rcon.Carnotaurus, rcon.Stegosaurus}
+	if !slices.Equal(playables, want) {
+		t.Errorf("got playables %v, want %v", playables, want)
+	}
+
+	entries, err := repl.auditLog.Entries(AuditFilter{Action: "UpdatePlayables"}.Match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Command != "classes allow Carnotaurus Stegosaurus" {
+		t.Errorf("got audit entries %+v", entries)
+	}
+}
+
+func TestPermissionRefused(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionReadOnly)
+
+	output := execute(t, repl, "kick Bob")
+	if !strings.Contains(output, "not allowed") {
+		t.Errorf("expected the command to be refused:\n%s", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Kicks) != 0 {
+		t.Errorf("nobody should have been kicked, got %+v", server.Kicks)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+
+	output := execute(t, repl, "fly")
+	if !strings.Contains(output, "Unknown command fly") {
+		t.Errorf("expected unknown command to be reported:\n%s", output)
+	}
+}
+
+func TestQuitCommand(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionReadOnly)
+
+	quit, err := repl.Execute("quit")
+	if err != nil || !quit {
+		t.Errorf("got %v, %v, want true, nil", quit, err)
+	}
+}
+
+func TestSendCommand(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	execute(t, repl, "send announce Testing")
+	execute(t, repl, "send 10 Again")
+
+	server.Lock()
+	announcements := server.Announcements
+	server.Unlock()
+	if !slices.Equal(announcements, []string{"Testing", "Again"}) {
+		t.Errorf("got announcements %q", announcements)
+	}
+
+	output := execute(t, repl, "send --hex players")
+	// "PlayerList" in hex
+	if !strings.Contains(output, "50 6c 61 79 65 72 4c 69  73 74") {
+		t.Errorf("expected a hexdump:\n%s", output)
+	}
+
+	start := time.Now()
+	output = execute(t, repl, "send --timeout 100ms 7f")
+	if !strings.Contains(output, "did not respond") {
+		t.Errorf("expected a timeout:\n%s", output)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("the timeout was ignored")
+	}
+
+	// The command is still waiting for a response. Drop the connection and
+	// wait until it gives up, so that it doesn't outlive the test.
+	server.Disconnect()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		entries, _ := repl.auditLog.Entries(AuditFilter{}.Match)
+		if slices.ContainsFunc(entries, func(e AuditEntry) bool { return len(e.Args) > 0 && e.Args[0] == "7f" }) {
+			return
+		}
+	}
+	t.Errorf("the command that timed out never finished")
+}
+
+func TestProbeCommand(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	server.Lock()
+	server.Handlers[0x2f] = func(command byte, params []string) (string, bool) {
+		return "Secret: " + strings.Join(params, "+"), true
+	}
+	server.Unlock()
+	repl.confirmer = newTestConfirmer(t, "y\n", nil)
+	repl.confirmer.interactive = true
+
+	report := filepath.Join(t.TempDir(), "report.txt")
+	output := execute(t, repl, "probe --params a,b 2f 30 "+report)
+	if !strings.Contains(output, "Report written") {
+		t.Errorf("expected a report:\n%s", output)
+	}
+
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1 responded, 0 timed out, 0 dropped the connection, 1 skipped", `2f  response`, `"Secret: a+b"`, "30  skipped   (kick)"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q in report:\n%s", want, content)
+		}
+	}
+
+	// Kick is on the deny-list
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Kicks) != 0 {
+		t.Errorf("nobody should have been kicked, got %+v", server.Kicks)
+	}
+}
+
+func TestProbeNeedsTerminal(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+
+	// The test REPL answers every question with yes, but probe still asks
+	output := execute(t, repl, "probe 2f 2f --yes")
+	if !strings.Contains(output, "confirmed in a terminal") {
+		t.Errorf("expected probe to refuse:\n%s", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if server.RequestsHandled != 0 {
+		t.Errorf("nothing should be sent, got %d requests", server.RequestsHandled)
+	}
+}
+
+func TestProbeReportInDataDirectory(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	dir := t.TempDir()
+	repl.dataPath = (&Config{DataDir: dir}).DataPath
+	repl.confirmer = newTestConfirmer(t, "y\n", nil)
+	repl.confirmer.interactive = true
+
+	execute(t, repl, "probe 30 30")
+
+	reports, err := filepath.Glob(filepath.Join(dir, "probe-*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Errorf("got reports %v, want 1 in the data directory", reports)
+	}
+}
+
+func TestFormatFlag(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionReadOnly)
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"status --format '{{.CurrentPlayers}}/{{.MaxPlayers}}'", "2/100\n"},
+		{"players --format {{.Name}} {{.DinoClass}} {{.Growth}}%", "Alice Carnotaurus 75%\nBob Stegosaurus 50%\n"},
+		{`info bob --format "{{.ID}}"`, bob.ID + "\n"},
+		{"status --format {{.Nope}}", "Invalid format"},
+		{"players --format {{", "Invalid format"},
+	}
+
+	for _, tt := range tests {
+		output := execute(t, repl, tt.line)
+		if !strings.HasPrefix(output, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.line, output, tt.want)
+		}
+	}
+}
+
+func TestYesFlagOnlyAtTheEnd(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionModerator)
+
+	execute(t, repl, "announce Type --yes to vote")
+	execute(t, repl, "announce Restart now --yes")
+
+	server.Lock()
+	defer server.Unlock()
+	want := []string{"Type --yes to vote", "Restart now"}
+	if !slices.Equal(server.Announcements, want) {
+		t.Errorf("got announcements %q, want %q", server.Announcements, want)
+	}
+}
+
+// slowClient doesn't answer one opcode in time.
+type slowClient struct {
+	Client
+	slow byte
+}
+
+func (c *slowClient) ExecCommand(command byte, params ...string) (string, error) {
+	if command == c.slow {
+		return "", os.ErrDeadlineExceeded
+	}
+	return "ok", nil
+}
+
+func TestProbeReconnectsAfterTimeout(t *testing.T) {
+	var reconnects []int
+	var results []ProbeResult
+	Probe(&slowClient{slow: 0x41}, func() { reconnects = append(reconnects, len(results)) }, 0x40, 0x42, nil, func(r ProbeResult) {
+		results = append(results, r)
+	})
+
+	var statuses []string
+	for _, r := range results {
+		statuses = append(statuses, r.Status)
+	}
+	if want := []string{ProbeResponded, ProbeTimeout, ProbeResponded}; !slices.Equal(statuses, want) {
+		t.Errorf("got %v, want %v", statuses, want)
+	}
+	// The connection is replaced before the next opcode is sent
+	if !slices.Equal(reconnects, []int{1}) {
+		t.Errorf("reconnected after %v results, want once after the timeout", reconnects)
+	}
+}
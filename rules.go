@@ -0,0 +1,464 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+const (
+	RuleClassLimit = "class_limit"
+	RuleZone       = "zone"
+	RuleAFK        = "afk"
+)
+
+type RulesConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// DryRun makes the engine log what it would do instead of warning or
+	// kicking anyone.
+	DryRun bool `json:"dry_run"`
+
+	IntervalSeconds int          `json:"interval_seconds"`
+	Rules           []RuleConfig `json:"rules"`
+}
+
+// RuleConfig describes a single server rule. Which fields are used depends on
+// the type of the rule.
+type RuleConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// Only players that match these filters are checked. An empty list of
+	// classes matches every class and a MaxGrowth of 0 means no upper limit.
+	Classes   []rcon.DinoClass `json:"classes"`
+	MinGrowth int8             `json:"min_growth"`
+	MaxGrowth int8             `json:"max_growth"`
+
+	// Limit is the maximum number of matching players for class_limit rules.
+	// If there are more, the players that joined last are in violation.
+	Limit int `json:"limit"`
+
+	// From and To are opposite corners of the box that players must not enter
+	// for zone rules, in the coordinates that the game shows, like in the
+	// zone file. If both Z values are 0, the altitude is ignored.
+	From RulePoint `json:"from"`
+	To   RulePoint `json:"to"`
+
+	// Minutes is how long a player may stay in the same location for afk
+	// rules.
+	Minutes float64 `json:"minutes"`
+
+	// Warnings is the number of direct messages a player receives before they
+	// are kicked. Message may contain the placeholders {name}, {rule},
+	// {warnings}, which is the number of warnings left, and {zone}, which is
+	// where the player is if there is a zone file.
+	Warnings   int    `json:"warnings"`
+	Message    string `json:"message"`
+	KickReason string `json:"kick_reason"`
+
+	// CooldownSeconds is the minimum time between two warnings, and between
+	// the last warning and the kick. The default is 60 seconds.
+	CooldownSeconds int `json:"cooldown_seconds"`
+}
+
+func (r *RuleConfig) validate() error {
+	switch r.Type {
+	case RuleClassLimit:
+		if r.Limit < 0 {
+			return fmt.Errorf("rule \"%s\": limit must not be negative", r.Name)
+		}
+	case RuleZone:
+	case RuleAFK:
+		if r.Minutes <= 0 {
+			return fmt.Errorf("rule \"%s\": minutes must be greater than 0", r.Name)
+		}
+	default:
+		return fmt.Errorf("rule \"%s\": unknown type \"%s\"", r.Name, r.Type)
+	}
+	return nil
+}
+
+func (r *RuleConfig) cooldown() time.Duration {
+	if r.CooldownSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(r.CooldownSeconds) * time.Second
+}
+
+// Describe returns a short human readable explanation of the rule.
+func (r *RuleConfig) Describe() string {
+	var who string
+	if len(r.Classes) > 0 {
+		names := make([]string, len(r.Classes))
+		for i, class := range r.Classes {
+			names[i] = class.Name()
+		}
+		who = strings.Join(names, "/")
+	} else {
+		who = "players"
+	}
+	if r.MinGrowth > 0 || r.MaxGrowth > 0 {
+		maxGrowth := r.MaxGrowth
+		if maxGrowth == 0 {
+			maxGrowth = 100
+		}
+		who += fmt.Sprintf(" with %d-%d%% growth", r.MinGrowth, maxGrowth)
+	}
+
+	switch r.Type {
+	case RuleClassLimit:
+		return fmt.Sprintf("At most %d %s", r.Limit, who)
+	case RuleZone:
+		return fmt.Sprintf("No %s between %.0f, %.0f and %.0f, %.0f", who, r.From.X, r.From.Y, r.To.X, r.To.Y)
+	case RuleAFK:
+		return fmt.Sprintf("No %s standing still for %g minutes", who, r.Minutes)
+	default:
+		return r.Type
+	}
+}
+
+func (r *RuleConfig) matches(player rcon.Player) bool {
+	if len(r.Classes) > 0 && !slices.Contains(r.Classes, player.DinoClass) {
+		return false
+	}
+	if player.Growth < r.MinGrowth {
+		return false
+	}
+	if r.MaxGrowth > 0 && player.Growth > r.MaxGrowth {
+		return false
+	}
+	return true
+}
+
+func (r *RuleConfig) contains(loc rcon.Location) bool {
+	between := func(v, a, b float64) bool {
+		return v >= min(a, b) && v <= max(a, b)
+	}
+	p := gamePoint(loc)
+	if !between(p.X, r.From.X, r.To.X) || !between(p.Y, r.From.Y, r.To.Y) {
+		return false
+	}
+	if r.From.Z == 0 && r.To.Z == 0 {
+		return true
+	}
+	return between(loc.Z, r.From.Z, r.To.Z)
+}
+
+// RulePoint is a corner of the box of a zone rule. Z is the altitude.
+type RulePoint struct {
+	ZonePoint
+	Z float64 `json:"z"`
+}
+
+// Violation is a player breaking a rule.
+type Violation struct {
+	Rule   *RuleConfig
+	Player rcon.Player
+}
+
+type violationKey struct {
+	rule     *RuleConfig
+	playerID string
+}
+
+type warningState struct {
+	count int
+	last  time.Time
+}
+
+type idleState struct {
+	location rcon.Location
+	since    time.Time
+}
+
+// RuleEngine periodically checks the players on the server against the
+// configured rules, warns players that break them and eventually kicks them.
+type RuleEngine struct {
+	*Poller
+	client Client
+	config RulesConfig
+	zones  *ZoneMap
+	log    func(string)
+
+	mutex     sync.Mutex
+	handlers  []func(v Violation, kicked bool)
+	firstSeen map[string]time.Time
+	idle      map[string]idleState
+	warnings  map[violationKey]warningState
+}
+
+// NewRuleEngine creates a rule engine. Every action it takes is reported
+// through log. If zones is not nil, messages say where the player is.
+func NewRuleEngine(client Client, config RulesConfig, zones *ZoneMap, log func(string)) (*RuleEngine, error) {
+	for i := range config.Rules {
+		if err := config.Rules[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	interval := time.Duration(config.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	e := &RuleEngine{
+		client:    client,
+		config:    config,
+		zones:     zones,
+		log:       log,
+		firstSeen: make(map[string]time.Time),
+		idle:      make(map[string]idleState),
+		warnings:  make(map[violationKey]warningState),
+	}
+	e.Poller = NewPoller(interval, e.Poll)
+	return e, nil
+}
+
+// OnViolation registers a function that is called whenever a player is warned
+// or kicked. It isn't called in dry runs.
+func (e *RuleEngine) OnViolation(handler func(v Violation, kicked bool)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.handlers = append(e.handlers, handler)
+}
+
+func (e *RuleEngine) notify(v Violation, kicked bool) {
+	e.mutex.Lock()
+	handlers := e.handlers
+	e.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(v, kicked)
+	}
+}
+
+func (e *RuleEngine) Rules() []RuleConfig {
+	return e.config.Rules
+}
+
+// Poll checks all players once and acts on every violation.
+func (e *RuleEngine) Poll() error {
+	players, err := e.client.GetPlayerData()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	e.observe(players, now)
+	violations := e.Check(players, now)
+
+	e.mutex.Lock()
+	active := make(map[violationKey]bool, len(violations))
+	for _, v := range violations {
+		active[violationKey{v.Rule, v.Player.ID}] = true
+	}
+	for key := range e.warnings {
+		if !active[key] {
+			delete(e.warnings, key)
+		}
+	}
+	e.mutex.Unlock()
+
+	// One failed kick shouldn't stop the engine from dealing with the other
+	// players
+	for _, v := range violations {
+		err := e.enforce(v, now)
+		if err != nil {
+			e.log(fmt.Sprintf("Cannot enforce rule \"%s\" on %s (%s): %v", v.Rule.Name, v.Player.Name, v.Player.ID, err))
+		}
+	}
+
+	return nil
+}
+
+// observe keeps track of when players were first seen and how long they have
+// been standing still. It should be called with fresh data regularly.
+func (e *RuleEngine) observe(players []rcon.Player, now time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	online := make(map[string]bool, len(players))
+	for _, player := range players {
+		online[player.ID] = true
+		if _, ok := e.firstSeen[player.ID]; !ok {
+			e.firstSeen[player.ID] = now
+		}
+		state, ok := e.idle[player.ID]
+		if !ok || state.location != player.Location {
+			e.idle[player.ID] = idleState{player.Location, now}
+		}
+	}
+	for id := range e.firstSeen {
+		if !online[id] {
+			delete(e.firstSeen, id)
+			delete(e.idle, id)
+		}
+	}
+}
+
+// Check returns all rule violations among players. It doesn't change the
+// state of the engine. Players that the engine hasn't seen before count as
+// having joined and stopped moving just now.
+func (e *RuleEngine) Check(players []rcon.Player, now time.Time) []Violation {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	firstSeen := func(id string) time.Time {
+		if t, ok := e.firstSeen[id]; ok {
+			return t
+		}
+		return now
+	}
+	idleSince := func(player rcon.Player) time.Time {
+		if state, ok := e.idle[player.ID]; ok && state.location == player.Location {
+			return state.since
+		}
+		return now
+	}
+
+	var violations []Violation
+
+	for i := range e.config.Rules {
+		rule := &e.config.Rules[i]
+
+		var matching []rcon.Player
+		for _, player := range players {
+			if rule.matches(player) {
+				matching = append(matching, player)
+			}
+		}
+
+		switch rule.Type {
+		case RuleClassLimit:
+			if len(matching) <= rule.Limit {
+				continue
+			}
+			slices.SortStableFunc(matching, func(a, b rcon.Player) int {
+				return firstSeen(a.ID).Compare(firstSeen(b.ID))
+			})
+			for _, player := range matching[rule.Limit:] {
+				violations = append(violations, Violation{rule, player})
+			}
+		case RuleZone:
+			for _, player := range matching {
+				if rule.contains(player.Location) {
+					violations = append(violations, Violation{rule, player})
+				}
+			}
+		case RuleAFK:
+			limit := time.Duration(rule.Minutes * float64(time.Minute))
+			for _, player := range matching {
+				if now.Sub(idleSince(player)) >= limit {
+					violations = append(violations, Violation{rule, player})
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+// enforce warns or kicks the player of a violation, unless they have been
+// warned less than the cooldown ago.
+func (e *RuleEngine) enforce(v Violation, now time.Time) error {
+	key := violationKey{v.Rule, v.Player.ID}
+
+	e.mutex.Lock()
+	state, seen := e.warnings[key]
+	if seen && now.Sub(state.last) < v.Rule.cooldown() {
+		e.mutex.Unlock()
+		return nil
+	}
+	warned := state.count
+	e.mutex.Unlock()
+
+	// The state only changes after the warning or the kick worked, so that
+	// a failed one is tried again on the next poll
+	remember := func(kicked bool) {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		if kicked {
+			delete(e.warnings, key)
+		} else {
+			e.warnings[key] = warningState{warned + 1, now}
+		}
+	}
+
+	prefix := ""
+	if e.config.DryRun {
+		prefix = "[dry run] "
+	}
+
+	// Mods want to know where to look
+	where := ""
+	zone := e.zones.Describe(v.Player.Location)
+	if zone != "" {
+		where = " at " + zone
+	}
+
+	if warned < v.Rule.Warnings {
+		message := v.Rule.Message
+		if message == "" {
+			message = "You are breaking the rule \"{rule}\". You will be kicked if you continue."
+		}
+		message = strings.NewReplacer(
+			"{name}", v.Player.Name,
+			"{rule}", v.Rule.Name,
+			"{warnings}", fmt.Sprint(v.Rule.Warnings-warned-1),
+			"{zone}", zone,
+		).Replace(message)
+
+		e.log(fmt.Sprintf("%sWarning %s (%s)%s for breaking rule \"%s\" (%d/%d)", prefix, v.Player.Name, v.Player.ID, where, v.Rule.Name, warned+1, v.Rule.Warnings))
+		if e.config.DryRun {
+			remember(false)
+			return nil
+		}
+		err := e.client.SendDirectMessage(v.Player.ID, message)
+		if err != nil {
+			return err
+		}
+		remember(false)
+		e.notify(v, false)
+		return nil
+	}
+
+	reason := v.Rule.KickReason
+	if reason == "" {
+		reason = fmt.Sprintf("You were kicked for breaking the rule \"%s\".", v.Rule.Name)
+	}
+
+	e.log(fmt.Sprintf("%sKicking %s (%s)%s for breaking rule \"%s\"", prefix, v.Player.Name, v.Player.ID, where, v.Rule.Name))
+	if e.config.DryRun {
+		remember(true)
+		return nil
+	}
+	err := e.client.KickPlayer(v.Player.ID, reason)
+	if err != nil {
+		return err
+	}
+	remember(true)
+	e.notify(v, true)
+	return nil
+}
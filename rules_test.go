@@ -0,0 +1,278 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func checkRule(t *testing.T, rule RuleConfig, rounds ...[]rcon.Player) [][]Violation {
+	t.Helper()
+
+	engine, err := NewRuleEngine(nil, RulesConfig{Rules: []RuleConfig{rule}}, nil, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var result [][]Violation
+	for _, players := range rounds {
+		engine.observe(players, now)
+		result = append(result, engine.Check(players, now))
+		now = now.Add(time.Minute)
+	}
+	return result
+}
+
+func violators(violations []Violation) []string {
+	ids := make([]string, len(violations))
+	for i, v := range violations {
+		ids[i] = v.Player.ID
+	}
+	return ids
+}
+
+func TestClassLimitRule(t *testing.T) {
+	rule := RuleConfig{Name: "carnos", Type: RuleClassLimit, Classes: []rcon.DinoClass{rcon.Carnotaurus}, MinGrowth: 50, Limit: 1}
+
+	first := rcon.Player{ID: "1", DinoClass: rcon.Carnotaurus, Growth: 75}
+	second := rcon.Player{ID: "2", DinoClass: rcon.Carnotaurus, Growth: 60}
+	juvenile := rcon.Player{ID: "3", DinoClass: rcon.Carnotaurus, Growth: 20}
+	other := rcon.Player{ID: "4", DinoClass: rcon.Stegosaurus, Growth: 75}
+
+	// The player that joined last is the one that breaks the limit, even if
+	// they are listed first
+	result := checkRule(t, rule,
+		[]rcon.Player{first, juvenile, other},
+		[]rcon.Player{second, first, juvenile, other},
+	)
+
+	if len(result[0]) != 0 {
+		t.Errorf("round 1: got violations %v, want none", violators(result[0]))
+	}
+	if ids := violators(result[1]); len(ids) != 1 || ids[0] != "2" {
+		t.Errorf("round 2: got violations %v, want [2]", ids)
+	}
+}
+
+func TestZoneRule(t *testing.T) {
+	rule := RuleConfig{
+		Name: "sanctuary",
+		Type: RuleZone,
+		From: RulePoint{ZonePoint: ZonePoint{X: 100, Y: 100}},
+		To:   RulePoint{ZonePoint: ZonePoint{X: -100, Y: -100}},
+	}
+
+	inside := rcon.Player{ID: "1", Location: rcon.Location{X: -20, Y: 50, Z: 3000}}
+	outside := rcon.Player{ID: "2", Location: at(0, 150)}
+
+	result := checkRule(t, rule, []rcon.Player{inside, outside})
+	if ids := violators(result[0]); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("got violations %v, want [1]", ids)
+	}
+}
+
+func TestAFKRule(t *testing.T) {
+	rule := RuleConfig{Name: "afk", Type: RuleAFK, Minutes: 2}
+
+	idle := rcon.Player{ID: "1", Location: rcon.Location{X: 1, Y: 2, Z: 3}}
+	moving := rcon.Player{ID: "2", Location: rcon.Location{X: 1, Y: 2, Z: 3}}
+	moved := moving
+	moved.Location.X = 5
+
+	result := checkRule(t, rule,
+		[]rcon.Player{idle, moving},
+		[]rcon.Player{idle, moved},
+		[]rcon.Player{idle, moved},
+	)
+
+	for i := 0; i < 2; i++ {
+		if len(result[i]) != 0 {
+			t.Errorf("round %d: got violations %v, want none", i+1, violators(result[i]))
+		}
+	}
+	if ids := violators(result[2]); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("round 3: got violations %v, want [1]", ids)
+	}
+}
+
+func TestInvalidRule(t *testing.T) {
+	_, err := NewRuleEngine(nil, RulesConfig{Rules: []RuleConfig{{Name: "broken", Type: "teleport"}}}, nil, func(string) {})
+	if err == nil {
+		t.Error("expected an error for an unknown rule type")
+	}
+}
+
+func TestCheckIsReadOnly(t *testing.T) {
+	engine, err := NewRuleEngine(nil, RulesConfig{Rules: []RuleConfig{{Name: "afk", Type: RuleAFK, Minutes: 1}}}, nil, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idle := rcon.Player{ID: "1", Location: rcon.Location{X: 1, Y: 2, Z: 3}}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if violations := engine.Check([]rcon.Player{idle}, now); len(violations) != 0 {
+			t.Errorf("check %d: got violations %v, want none", i+1, violators(violations))
+		}
+		now = now.Add(2 * time.Minute)
+	}
+	if len(engine.firstSeen) != 0 || len(engine.idle) != 0 {
+		t.Error("Check should not remember players")
+	}
+}
+
+func TestWarningCooldown(t *testing.T) {
+	var logs []string
+	rule := RuleConfig{Name: "afk", Type: RuleAFK, Minutes: 5, Warnings: 2, CooldownSeconds: 60}
+	engine, err := NewRuleEngine(nil, RulesConfig{DryRun: true, Rules: []RuleConfig{rule}}, nil, func(message string) {
+		logs = append(logs, message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := Violation{&engine.config.Rules[0], rcon.Player{ID: "1", Name: "Idle"}}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, seconds := range []int{0, 30, 60, 90, 120} {
+		if err := engine.enforce(v, now.Add(time.Duration(seconds)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"Warning Idle", "Warning Idle", "Kicking Idle"}
+	if len(logs) != len(want) {
+		t.Fatalf("logs = %q, want %d entries", logs, len(want))
+	}
+	for i := range want {
+		if !strings.Contains(logs[i], want[i]) {
+			t.Errorf("log %d = %q, want %q", i, logs[i], want[i])
+		}
+	}
+}
+
+// failingClient returns a fixed list of players and can't warn or kick one
+// of them.
+type failingClient struct {
+	Client
+	players []rcon.Player
+	failID  string
+	kicked  []string
+}
+
+func (c *failingClient) GetPlayerData() ([]rcon.Player, error) {
+	return c.players, nil
+}
+
+func (c *failingClient) SendDirectMessage(playerID, message string) error {
+	if playerID == c.failID {
+		return errors.New("message failed")
+	}
+	return nil
+}
+
+func (c *failingClient) KickPlayer(playerID, reason string) error {
+	if playerID == c.failID {
+		return errors.New("kick failed")
+	}
+	c.kicked = append(c.kicked, playerID)
+	return nil
+}
+
+func TestPollContinuesAfterError(t *testing.T) {
+	client := &failingClient{
+		players: []rcon.Player{{ID: "1", Name: "First"}, {ID: "2", Name: "Second"}},
+		failID:  "1",
+	}
+	var logs []string
+	rule := RuleConfig{Name: "sanctuary", Type: RuleZone, From: RulePoint{ZonePoint: ZonePoint{X: -10, Y: -10}}, To: RulePoint{ZonePoint: ZonePoint{X: 10, Y: 10}}}
+	engine, err := NewRuleEngine(client, RulesConfig{Rules: []RuleConfig{rule}}, nil, func(message string) {
+		logs = append(logs, message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notified []string
+	engine.OnViolation(func(v Violation, kicked bool) {
+		notified = append(notified, v.Player.ID)
+	})
+
+	if err := engine.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(notified) != 1 || notified[0] != "2" {
+		t.Errorf("got violations %v, want only the kick that worked", notified)
+	}
+	if len(client.kicked) != 1 || client.kicked[0] != "2" {
+		t.Errorf("kicked %v, want [2]", client.kicked)
+	}
+	if !slices.ContainsFunc(logs, func(message string) bool { return strings.Contains(message, "kick failed") }) {
+		t.Errorf("expected the error in the log: %q", logs)
+	}
+}
+
+func TestFailedWarningIsNotCounted(t *testing.T) {
+	client := &failingClient{players: []rcon.Player{{ID: "1", Name: "First"}}, failID: "1"}
+	rule := RuleConfig{Name: "sanctuary", Type: RuleZone, From: RulePoint{ZonePoint: ZonePoint{X: -10, Y: -10}}, To: RulePoint{ZonePoint: ZonePoint{X: 10, Y: 10}}, Warnings: 1}
+	engine, err := NewRuleEngine(client, RulesConfig{Rules: []RuleConfig{rule}}, nil, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := Violation{&engine.config.Rules[0], client.players[0]}
+	key := violationKey{v.Rule, "1"}
+	now := time.Now()
+
+	if err := engine.enforce(v, now); err == nil {
+		t.Fatal("expected the warning to fail")
+	}
+	if state, ok := engine.warnings[key]; ok {
+		t.Errorf("got %+v after a failed warning, want no warnings", state)
+	}
+
+	// The warning is sent again right away and counts once it worked
+	client.failID = ""
+	if err := engine.enforce(v, now); err != nil {
+		t.Fatal(err)
+	}
+	if state := engine.warnings[key]; state.count != 1 {
+		t.Errorf("got %d warnings, want 1", state.count)
+	}
+
+	// A failed kick keeps the warnings, so the kick is tried again
+	client.failID = "1"
+	now = now.Add(2 * time.Minute)
+	if err := engine.enforce(v, now); err == nil {
+		t.Fatal("expected the kick to fail")
+	}
+	if state := engine.warnings[key]; state.count != 1 {
+		t.Errorf("got %d warnings after a failed kick, want 1", state.count)
+	}
+	client.failID = ""
+	if err := engine.enforce(v, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.kicked) != 1 {
+		t.Errorf("kicked %v, want the player", client.kicked)
+	}
+}
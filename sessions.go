@@ -0,0 +1,369 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+	_ "modernc.org/sqlite"
+)
+
+type SessionsConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// The names that players used are in the name cache, the database only has
+// the name of each session.
+const sessionSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id          INTEGER PRIMARY KEY,
+	player_id   TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	started     INTEGER NOT NULL,
+	ended       INTEGER,
+	last_update INTEGER NOT NULL,
+	class       TEXT NOT NULL DEFAULT '',
+	peak_growth INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS sessions_player ON sessions (player_id);
+`
+
+// Session is a single visit of a player on the server. End is the zero time
+// while the session is still going on.
+type Session struct {
+	PlayerID   string
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Class      rcon.DinoClass
+	PeakGrowth int8
+}
+
+// PlayerRecord is everything the session store knows about a player.
+type PlayerRecord struct {
+	ID       string
+	Names    []string
+	LastSeen time.Time
+	Online   bool
+}
+
+// Playtime is the total time a player spent on the server.
+type Playtime struct {
+	PlayerID string
+	Name     string
+	Duration time.Duration
+	Sessions int
+}
+
+var ErrUnknownPlayer = errors.New("unknown player")
+
+// SessionStore keeps a record of who played on the server and when in an
+// SQLite database. Names are looked up in the name cache.
+type SessionStore struct {
+	db    *sql.DB
+	names *NameCache
+}
+
+func OpenSessionStore(path string, names *NameCache) (*SessionStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sessionSchema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Sessions that are still open were interrupted by the program exiting,
+	// so they end at the last time we saw the player.
+	_, err = db.Exec("UPDATE sessions SET ended = last_update WHERE ended IS NULL")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SessionStore{db, names}, nil
+}
+
+func (s *SessionStore) Close() error {
+	return s.db.Close()
+}
+
+// StartSession opens a new session, unless the player already has one.
+func (s *SessionStore) StartSession(player rcon.Player, now time.Time) error {
+	var open int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE player_id = ? AND ended IS NULL", player.ID).Scan(&open)
+	if err != nil {
+		return err
+	}
+	if open > 0 {
+		return nil
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO sessions (player_id, name, started, last_update, class, peak_growth) VALUES (?, ?, ?, ?, ?, ?)",
+		player.ID, player.Name, now.Unix(), now.Unix(), string(player.DinoClass), player.Growth,
+	)
+	return err
+}
+
+// EndSession closes the open session of a player.
+func (s *SessionStore) EndSession(playerID string, now time.Time) error {
+	_, err := s.db.Exec("UPDATE sessions SET ended = ?, last_update = ? WHERE player_id = ? AND ended IS NULL", now.Unix(), now.Unix(), playerID)
+	return err
+}
+
+// UpdateSession records the current class and growth of a player. Players
+// without a class are still choosing one, so only the time is updated for
+// them. Players without an open session are ignored, because the data may be
+// older than the event that ended their session.
+func (s *SessionStore) UpdateSession(player rcon.Player, now time.Time) error {
+	if player.DinoClass == "" {
+		_, err := s.db.Exec("UPDATE sessions SET last_update = ? WHERE player_id = ? AND ended IS NULL", now.Unix(), player.ID)
+		return err
+	}
+	_, err := s.db.Exec(
+		"UPDATE sessions SET last_update = ?, class = ?, peak_growth = MAX(peak_growth, ?) WHERE player_id = ? AND ended IS NULL",
+		now.Unix(), string(player.DinoClass), player.Growth, player.ID,
+	)
+	return err
+}
+
+// FindPlayer looks up a player by ID or by any name they ever used. Names are
+// compared like everywhere else, see normalizeName. If several players used
+// the same name, the one that was seen last is returned.
+func (s *SessionStore) FindPlayer(nameOrID string) (*PlayerRecord, error) {
+	var open, sessions int
+	var lastSeen sql.NullInt64
+	var lastName sql.NullString
+	query := func(id string) error {
+		return s.db.QueryRow(
+			`SELECT COUNT(*) FILTER (WHERE ended IS NULL), COUNT(*), MAX(COALESCE(ended, last_update)),
+				(SELECT name FROM sessions WHERE player_id = ? ORDER BY started DESC LIMIT 1)
+			FROM sessions WHERE player_id = ?`,
+			id, id,
+		).Scan(&open, &sessions, &lastSeen, &lastName)
+	}
+
+	id := nameOrID
+	err := query(id)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == 0 && len(s.names.Names(id)) == 0 {
+		entries := s.names.Lookup(nameOrID)
+		if len(entries) == 0 {
+			return nil, ErrUnknownPlayer
+		}
+		id = entries[0].ID
+		err = query(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	record := &PlayerRecord{ID: id, Online: open > 0}
+	for _, entry := range s.names.Names(id) {
+		record.Names = append(record.Names, entry.Name)
+		if entry.LastSeen.After(record.LastSeen) {
+			record.LastSeen = entry.LastSeen
+		}
+	}
+	if len(record.Names) == 0 {
+		if !lastName.Valid {
+			return nil, ErrUnknownPlayer
+		}
+		record.Names = []string{lastName.String}
+	}
+	if t := time.Unix(lastSeen.Int64, 0); lastSeen.Valid && t.After(record.LastSeen) {
+		record.LastSeen = t
+	}
+
+	return record, nil
+}
+
+// Sessions returns the most recent sessions of a player, newest first.
+func (s *SessionStore) Sessions(playerID string, limit int) ([]Session, error) {
+	rows, err := s.db.Query(
+		"SELECT player_id, name, started, ended, class, peak_growth FROM sessions WHERE player_id = ? ORDER BY started DESC LIMIT ?",
+		playerID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		var start int64
+		var end sql.NullInt64
+		var class string
+		err = rows.Scan(&session.PlayerID, &session.Name, &start, &end, &class, &session.PeakGrowth)
+		if err != nil {
+			return nil, err
+		}
+		session.Start = time.Unix(start, 0)
+		if end.Valid {
+			session.End = time.Unix(end.Int64, 0)
+		}
+		session.Class = rcon.DinoClass(class)
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Playtime returns how long a player was on the server since a point in time.
+func (s *SessionStore) Playtime(playerID string, since, now time.Time) (*Playtime, error) {
+	top, err := s.topPlaytime("AND player_id = ?", since, now, 1, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(top) == 0 {
+		return &Playtime{PlayerID: playerID}, nil
+	}
+	return &top[0], nil
+}
+
+// TopPlaytime returns the players with the most playtime since a point in
+// time.
+func (s *SessionStore) TopPlaytime(since, now time.Time, limit int) ([]Playtime, error) {
+	return s.topPlaytime("", since, now, limit)
+}
+
+func (s *SessionStore) topPlaytime(filter string, since, now time.Time, limit int, args ...any) ([]Playtime, error) {
+	// Sessions that started before since only count from since and open
+	// sessions count until now.
+	query := `
+		SELECT player_id,
+			(SELECT name FROM sessions AS s WHERE s.player_id = sessions.player_id ORDER BY started DESC LIMIT 1),
+			SUM(MAX(0, COALESCE(ended, ?) - MAX(started, ?))),
+			COUNT(*)
+		FROM sessions
+		WHERE COALESCE(ended, ?) > ? ` + filter + `
+		GROUP BY player_id
+		ORDER BY 3 DESC
+		LIMIT ?`
+
+	params := []any{now.Unix(), since.Unix(), now.Unix(), since.Unix()}
+	params = append(params, args...)
+	params = append(params, limit)
+
+	rows, err := s.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Playtime
+	for rows.Next() {
+		var p Playtime
+		var seconds int64
+		err = rows.Scan(&p.PlayerID, &p.Name, &seconds, &p.Sessions)
+		if err != nil {
+			return nil, err
+		}
+		p.Duration = time.Duration(seconds) * time.Second
+		if names := s.names.Names(p.PlayerID); len(names) > 0 {
+			p.Name = names[0].Name
+		}
+		result = append(result, p)
+	}
+
+	return result, rows.Err()
+}
+
+// SessionTracker writes join and leave events and the players' class and
+// growth into a session store.
+type SessionTracker struct {
+	*Poller
+	client Client
+	store  *SessionStore
+	log    func(error)
+
+	// The watcher doesn't report players that were already online when it
+	// started, so their sessions are started by the first poll
+	started bool
+}
+
+func NewSessionTracker(client Client, store *SessionStore, interval time.Duration, log func(error)) *SessionTracker {
+	t := &SessionTracker{
+		client: client,
+		store:  store,
+		log:    log,
+	}
+	t.Poller = NewPoller(interval, t.Poll)
+	return t
+}
+
+// Handle is meant to be subscribed to a PlayerWatcher.
+func (t *SessionTracker) Handle(event PlayerEvent) {
+	var err error
+	switch event.Type {
+	case PlayerJoined:
+		err = t.store.StartSession(event.Player, event.Time)
+	case PlayerLeft:
+		err = t.store.EndSession(event.Player.ID, event.Time)
+	}
+	if err != nil {
+		t.log(err)
+	}
+}
+
+// Poll records the class and growth of every player that is online. Only
+// the first poll starts sessions, everything after that is up to Handle.
+// Players that are still choosing a class are only in the player list, so
+// that is where the players come from.
+func (t *SessionTracker) Poll() error {
+	players, err := t.client.GetPlayerList()
+	if err != nil {
+		return err
+	}
+	data, err := t.client.GetPlayerData()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, player := range players {
+		if i := slices.IndexFunc(data, func(p rcon.Player) bool { return p.ID == player.ID }); i >= 0 {
+			player = data[i]
+		}
+		if !t.started {
+			err = t.store.StartSession(player, now)
+			if err != nil {
+				return err
+			}
+		}
+		err = t.store.UpdateSession(player, now)
+		if err != nil {
+			return err
+		}
+	}
+	t.started = true
+
+	return nil
+}
@@ -0,0 +1,220 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func openTestSessionStore(t *testing.T, names *NameCache) *SessionStore {
+	t.Helper()
+	store, err := OpenSessionStore(filepath.Join(t.TempDir(), "sessions.db"), names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSessionTrackerDoesNotReopenSessions(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+
+	store := openTestSessionStore(t, nil)
+	tracker := NewSessionTracker(repl.wrapClient("sessions"), store, time.Second, func(error) {})
+
+	// Players that are online at the start get a session from the first poll
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob leaves, but a poll still sees him, like it can happen when the
+	// player list and the player data are a bit apart
+	tracker.Handle(PlayerEvent{PlayerLeft, bob, time.Now()})
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := store.Sessions(bob.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].End.IsZero() {
+		t.Errorf("got sessions %+v, want a single closed session", sessions)
+	}
+
+	sessions, err = store.Sessions(alice.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || !sessions[0].End.IsZero() {
+		t.Errorf("got sessions %+v, want a single open session", sessions)
+	}
+}
+
+func TestSessionTrackerClosesSessionsOnLeave(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	store := openTestSessionStore(t, nil)
+	tracker := NewSessionTracker(repl.wrapClient("sessions"), store, time.Second, func(error) {})
+
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Handle(PlayerEvent{PlayerLeft, alice, time.Now()})
+
+	sessions, err := store.Sessions(alice.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].End.IsZero() {
+		t.Errorf("got sessions %+v, want a single closed session", sessions)
+	}
+	if sessions[0].Class != alice.DinoClass {
+		t.Errorf("got class %q, want %q", sessions[0].Class, alice.DinoClass)
+	}
+}
+
+func TestSessionTrackerCountsPlayersInClassSelection(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	store := openTestSessionStore(t, nil)
+	client := &classSelectionClient{repl.wrapClient("sessions"), bob.ID}
+	tracker := NewSessionTracker(client, store, time.Second, func(error) {})
+
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := store.Sessions(bob.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || !sessions[0].End.IsZero() {
+		t.Fatalf("got sessions %+v, want a single open session", sessions)
+	}
+
+	// Once Bob picked a class, the session gets it
+	client.choosing = ""
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err = store.Sessions(bob.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Class != bob.DinoClass {
+		t.Errorf("got sessions %+v, want a single session as %s", sessions, bob.DinoClass)
+	}
+}
+
+func TestPlaytime(t *testing.T) {
+	store := openTestSessionStore(t, nil)
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Alice plays twice for an hour, Bob plays once and is still online
+	play := func(player rcon.Player, from, to time.Duration) {
+		if err := store.StartSession(player, start.Add(from)); err != nil {
+			t.Fatal(err)
+		}
+		if to == 0 {
+			return
+		}
+		if err := store.EndSession(player.ID, start.Add(to)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	play(alice, 0, time.Hour)
+	play(alice, 2*time.Hour, 3*time.Hour)
+	play(bob, 90*time.Minute, 0)
+	now := start.Add(4 * time.Hour)
+
+	playtime, err := store.Playtime(alice.ID, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playtime.Duration != 2*time.Hour || playtime.Sessions != 2 {
+		t.Errorf("got %v in %d sessions, want 2h in 2 sessions", playtime.Duration, playtime.Sessions)
+	}
+
+	// Only the part of a session after since counts
+	playtime, err = store.Playtime(alice.ID, start.Add(150*time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playtime.Duration != 30*time.Minute || playtime.Sessions != 1 {
+		t.Errorf("got %v in %d sessions, want 30m in 1 session", playtime.Duration, playtime.Sessions)
+	}
+
+	top, err := store.TopPlaytime(time.Time{}, now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Name != bob.Name || top[0].Duration != 150*time.Minute || top[1].Name != alice.Name {
+		t.Errorf("got %+v, want Bob with 2h30m before Alice", top)
+	}
+}
+
+func TestSeenOfflinePlayer(t *testing.T) {
+	names, err := LoadNameCache(filepath.Join(t.TempDir(), "names.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := openTestSessionStore(t, names)
+
+	// Alice played as "Ally" before she renamed herself
+	now := time.Now()
+	ally := alice
+	ally.Name = "Ally"
+	if err := names.Observe([]rcon.Player{ally}, now.Add(-3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := names.Observe([]rcon.Player{alice}, now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StartSession(alice, now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.EndSession(alice.ID, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"ally", alice.ID} {
+		output := captureOutput(t, func() {
+			if err := seenCommand(store, []string{name}); err != nil {
+				t.Error(err)
+			}
+		})
+		if !strings.Contains(output, "Alice ("+alice.ID+") was last seen") || !strings.Contains(output, "1h 0m ago") {
+			t.Errorf("seen %s: got %q, want Alice last seen an hour ago", name, output)
+		}
+		if !strings.Contains(output, "Also known as: Ally\n") {
+			t.Errorf("seen %s: got %q, want Ally as another name", name, output)
+		}
+	}
+
+	output := captureOutput(t, func() {
+		if err := seenCommand(store, []string{"Carol"}); err != nil {
+			t.Error(err)
+		}
+	})
+	if output != "\"Carol\" has never been seen on the server\n" {
+		t.Errorf("got %q, want Carol to be unknown", output)
+	}
+}
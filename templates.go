@@ -0,0 +1,151 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// defaultTemplates are the message templates that exist unless the config
+// replaces them.
+var defaultTemplates = map[string]string{
+	"rules":   "Welcome to {server}! Please read the rules on our Discord before you play.",
+	"restart": "The server will restart in {minutes} minutes. Please find a safe spot to log out.",
+	"nocamp":  "{player}, please don't camp as a {class}. Camping is against the rules on {server}.",
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Expand turns a template reference like "@restart minutes=10" into the
+// message that it stands for. Every KEY=VALUE argument fills the placeholder
+// {KEY}. {server} is the name of the server and {class} is the class of the
+// player named by player=NAME. ok is false if the template can't be
+// expanded, which has already been reported to the user.
+func (c MessagesConfig) Expand(client Client, args []string) (message string, ok bool, err error) {
+	name := strings.TrimPrefix(args[0], "@")
+	template, exists := c.Templates[name]
+	if !exists {
+		fmt.Printf("Unknown template \"%s\". Type \"templates\" to see all templates.\n", name)
+		return "", false, nil
+	}
+
+	values := make(map[string]string)
+	for _, arg := range args[1:] {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || key == "" {
+			fmt.Printf("Invalid argument \"%s\", expected KEY=VALUE\n", arg)
+			return "", false, nil
+		}
+		values[strings.ToLower(key)] = value
+	}
+
+	placeholders := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		placeholders[match[1]] = true
+	}
+
+	if placeholders["server"] && values["server"] == "" {
+		details, err := client.GetServerDetails()
+		if err != nil {
+			return "", false, err
+		}
+		values["server"] = details.Name
+	}
+
+	if placeholders["class"] && values["class"] == "" && values["player"] != "" {
+		players, err := client.GetPlayerData()
+		if err != nil {
+			return "", false, err
+		}
+		name := normalizeName(values["player"])
+		i := slices.IndexFunc(players, func(p rcon.Player) bool { return normalizeName(p.Name) == name })
+		if i < 0 {
+			fmt.Printf("Player \"%s\" not found\n", values["player"])
+			printSuggestions(players, values["player"])
+			return "", false, nil
+		}
+		values["class"] = string(players[i].DinoClass)
+	}
+
+	var missing []string
+	message = placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := placeholder[1 : len(placeholder)-1]
+		value, found := values[key]
+		if !found {
+			missing = append(missing, key)
+			return placeholder
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		fmt.Printf("Template \"%s\" needs %s\n", name, strings.Join(slices.Compact(missing), "=... ")+"=...")
+		return "", false, nil
+	}
+
+	return message, true, nil
+}
+
+// templateReference checks whether the arguments of announce or dm start with
+// the name of a template. Text like "@everyone" that doesn't name a template
+// is sent as typed. A leading "@@" is turned into "@" and never names a
+// template, so "@@rules" sends "@rules".
+func (c MessagesConfig) templateReference(args []string) (text []string, isTemplate bool) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "@") {
+		return args, false
+	}
+	if strings.HasPrefix(args[0], "@@") {
+		text = slices.Clone(args)
+		text[0] = text[0][1:]
+		return text, false
+	}
+	_, exists := c.Templates[args[0][1:]]
+	return args, exists
+}
+
+func templatesCommand(client Client, messages MessagesConfig, args []string) error {
+	if len(args) == 0 {
+		if len(messages.Templates) == 0 {
+			fmt.Println("There are no templates")
+			return nil
+		}
+		names := slices.Sorted(maps.Keys(messages.Templates))
+		width := 0
+		for _, name := range names {
+			width = max(width, len(name)+1)
+		}
+		for _, name := range names {
+			fmt.Printf("%-*s  %s\n", width, "@"+name, messages.Templates[name])
+		}
+		return nil
+	}
+
+	args[0] = "@" + strings.TrimPrefix(args[0], "@")
+	message, ok, err := messages.Expand(client, args)
+	if err != nil || !ok {
+		return err
+	}
+	fmt.Println(message)
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	repl.messages = MessagesConfig{Templates: defaultTemplates}
+
+	execute(t, repl, "announce @restart minutes=10")
+	execute(t, repl, "dm Bob @nocamp")
+	// The template gets the name of the player, not what was typed
+	execute(t, repl, "dm "+bob.ID+" @nocamp")
+	execute(t, repl, "dm ｂｏｂ @nocamp")
+	// Partial names aren't guessed, not even for templates
+	if output := execute(t, repl, "dm Bo @nocamp"); !strings.Contains(output, `Did you mean "Bob"?`) {
+		t.Errorf("dm Bo: got %q, want a suggestion", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Announcements) != 1 || !strings.HasPrefix(server.Announcements[0], "The server will restart in 10 minutes.") {
+		t.Errorf("announcements = %q", server.Announcements)
+	}
+	want := "Bob, please don't camp as a Stegosaurus. Camping is against the rules on Fake Server."
+	if len(server.DirectMessages) != 3 {
+		t.Fatalf("direct messages = %v, want 3", server.DirectMessages)
+	}
+	for _, dm := range server.DirectMessages {
+		if dm.PlayerID != bob.ID || dm.Text != want {
+			t.Errorf("direct message = %v, want %q to Bob", dm, want)
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	repl.messages = MessagesConfig{Templates: defaultTemplates}
+
+	tests := map[string]string{
+		"announce @restart":              `Template "restart" needs minutes=...`,
+		"templates unknown":              `Unknown template "unknown"`,
+		"announce @restart 10":           `Invalid argument "10"`,
+		"templates nocamp player=Nobody": `Player "Nobody" not found`,
+	}
+	for line, want := range tests {
+		if output := execute(t, repl, line); !strings.Contains(output, want) {
+			t.Errorf("%s: got %q, want %q", line, output, want)
+		}
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Announcements) != 0 {
+		t.Errorf("announcements = %q, want none", server.Announcements)
+	}
+}
+
+func TestTextThatIsNoTemplate(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	repl.messages = MessagesConfig{Templates: defaultTemplates}
+
+	execute(t, repl, "announce @everyone event starts now")
+	execute(t, repl, "announce @@rules are on Discord")
+	execute(t, repl, "dm Bob @@nocamp")
+
+	server.Lock()
+	defer server.Unlock()
+	want := []string{"@everyone event starts now", "@rules are on Discord"}
+	if !slices.Equal(server.Announcements, want) {
+		t.Errorf("announcements = %q, want %q", server.Announcements, want)
+	}
+	if len(server.DirectMessages) != 1 || server.DirectMessages[0].Text != "@nocamp" {
+		t.Errorf("direct messages = %v", server.DirectMessages)
+	}
+}
+
+func TestTemplatesCommand(t *testing.T) {
+	repl, _ := newTestRepl(t, PermissionReadOnly)
+	repl.messages = MessagesConfig{Templates: defaultTemplates}
+
+	output := execute(t, repl, "templates")
+	for name := range defaultTemplates {
+		if !strings.Contains(output, "@"+name) {
+			t.Errorf("template %s is not listed:\n%s", name, output)
+		}
+	}
+
+	output = execute(t, repl, "templates @nocamp player=Alice")
+	if want := "Alice, please don't camp as a Carnotaurus."; !strings.HasPrefix(output, want) {
+		t.Errorf("got %q, want %q", output, want)
+	}
+}
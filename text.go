@@ -0,0 +1,94 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// normalizeName prepares a name for comparisons. Names that look the same to
+// a player, like "Rexy", "REXY" and "Ｒｅｘｙ" in full-width letters, are
+// normalized to the same string.
+func normalizeName(name string) string {
+	// This is the NFKC_Casefold mapping of Unicode, minus the removal of
+	// ignorable characters
+	folded := cases.Fold().String(norm.NFKC.String(name))
+	return norm.NFKC.String(folded)
+}
+
+// runeWidth returns how many columns r takes up in a terminal.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r):
+		// Zero width joiners, combining marks and variation selectors
+		return 0
+	case !unicode.IsPrint(r):
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// zeroWidthJoiner glues emoji together, e.g. to show a family as one emoji.
+const zeroWidthJoiner = '\u200d'
+
+// displayWidth returns how many columns s takes up in a terminal.
+func displayWidth(s string) int {
+	n := 0
+	previous := rune(0)
+	for _, r := range s {
+		if previous != zeroWidthJoiner {
+			n += runeWidth(r)
+		}
+		previous = r
+	}
+	return n
+}
+
+// padRight fills s with spaces until it is n columns wide, so that names with
+// wide characters like emoji line up in tables.
+func padRight(s string, n int) string {
+	if w := displayWidth(s); w < n {
+		return s + strings.Repeat(" ", n-w)
+	}
+	return s
+}
+
+// truncate cuts s to at most n columns.
+func truncate(s string, n int) string {
+	w := 0
+	previous := rune(0)
+	for i, r := range s {
+		if previous != zeroWidthJoiner {
+			w += runeWidth(r)
+		}
+		if w > n {
+			return s[:i]
+		}
+		previous = r
+	}
+	return s
+}
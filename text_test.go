@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Rexy", "REXY"},
+		{"Rexy", "Ｒｅｘｙ"},
+		{"Straße", "STRASSE"},
+		{"Café", "Café"},
+		{"ΣΊΣΥΦΟΣ", "σίσυφος"},
+		{"[ᴬᴮᶜ] Rex", "[ABC] rex"},
+	}
+	for _, test := range tests {
+		if a, b := normalizeName(test.a), normalizeName(test.b); a != b {
+			t.Errorf("normalizeName(%q) = %q, normalizeName(%q) = %q, want equal", test.a, a, test.b, b)
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"Rexy", 4},
+		{"Ｒｅｘｙ", 8},
+		{"🦖Rex", 5},
+		{"Café", 4},
+		{"👨\u200d👩\u200d👧", 2},
+		{"恐竜", 4},
+	}
+	for _, test := range tests {
+		if got := displayWidth(test.s); got != test.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", test.s, got, test.want)
+		}
+	}
+}
+
+func TestPadAndTruncate(t *testing.T) {
+	if got := padRight("🦖Rex", 8); got != "🦖Rex   " {
+		t.Errorf("padRight = %q", got)
+	}
+	if got := padRight("TooLong", 3); got != "TooLong" {
+		t.Errorf("padRight = %q, long text must not be cut", got)
+	}
+	if got := truncate("恐竜Rex", 3); got != "恐" {
+		t.Errorf("truncate = %q, want %q", got, "恐")
+	}
+	if got := truncate("Rex", 10); got != "Rex" {
+		t.Errorf("truncate = %q, want %q", got, "Rex")
+	}
+}
+
+func TestResolveNormalizedNames(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	server.AddPlayer(rcon.Player{ID: "76561198000000005", Name: "Ｒｅｘｙ🦖"})
+	server.AddPlayer(rcon.Player{ID: "76561198000000006", Name: "REXY🦖"})
+
+	// Both names match, so none of them may be guessed
+	for _, line := range []string{"dm rexy🦖 Hello", "kick rexy🦖", "info rexy🦖"} {
+		output := execute(t, repl, line)
+		if !strings.Contains(output, "Several players are called") || !strings.Contains(output, "76561198000000005") || !strings.Contains(output, "76561198000000006") {
+			t.Errorf("%s: got %q, want both players", line, output)
+		}
+	}
+
+	// An exact match or an ID still works
+	execute(t, repl, "dm REXY🦖 Hello")
+	execute(t, repl, "dm 76561198000000005 Hello")
+	output := execute(t, repl, "info ALICE")
+	if !strings.Contains(output, alice.ID) {
+		t.Errorf("info ALICE: got %q", output)
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.Kicks) != 0 {
+		t.Errorf("kicks = %v, want none", server.Kicks)
+	}
+	if len(server.DirectMessages) != 2 || server.DirectMessages[0].PlayerID != "76561198000000006" || server.DirectMessages[1].PlayerID != "76561198000000005" {
+		t.Errorf("direct messages = %v, want the exact match and then the ID", server.DirectMessages)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+const defaultPollInterval = 10 * time.Second
+
+type PlayerEventType int
+
+const (
+	PlayerJoined PlayerEventType = iota
+	PlayerLeft
+)
+
+func (t PlayerEventType) String() string {
+	switch t {
+	case PlayerJoined:
+		return "joined"
+	case PlayerLeft:
+		return "left"
+	default:
+		return "unknown"
+	}
+}
+
+// PlayerEvent is emitted by a PlayerWatcher whenever a player joins or leaves
+// the server.
+type PlayerEvent struct {
+	Type   PlayerEventType
+	Player rcon.Player
+	Time   time.Time
+}
+
+func (e PlayerEvent) String() string {
+	return fmt.Sprintf("%s (%s) %s the server", e.Player.Name, e.Player.ID, e.Type)
+}
+
+// PlayerWatcher polls the player list of the server and emits events when
+// players join or leave. The Evrima RCON does not push anything to clients, so
+// this is the only way to find out about these things.
+type PlayerWatcher struct {
+	*Poller
+	client Client
+
+	mutex    sync.Mutex
+	players  map[string]rcon.Player
+	handlers []func(PlayerEvent)
+}
+
+func NewPlayerWatcher(client Client, interval time.Duration) *PlayerWatcher {
+	w := &PlayerWatcher{client: client}
+	w.Poller = NewPoller(interval, w.Poll)
+	return w
+}
+
+// Subscribe registers a function that is called for every event. Handlers are
+// called from the polling goroutine, so they should not block for long.
+func (w *PlayerWatcher) Subscribe(handler func(PlayerEvent)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.handlers = append(w.handlers, handler)
+}
+
+// Players returns the players that were online during the last poll.
+func (w *PlayerWatcher) Players() []rcon.Player {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	players := make([]rcon.Player, 0, len(w.players))
+	for _, player := range w.players {
+		players = append(players, player)
+	}
+	return players
+}
+
+// Poll fetches the player list once and emits events for every difference to
+// the previous poll. The first poll only records who is online and does not
+// emit any events.
+func (w *PlayerWatcher) Poll() error {
+	list, err := w.client.GetPlayerList()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	current := make(map[string]rcon.Player, len(list))
+	for _, player := range list {
+		current[player.ID] = player
+	}
+
+	w.mutex.Lock()
+	previous := w.players
+	w.players = current
+	handlers := w.handlers
+	w.mutex.Unlock()
+
+	if previous == nil {
+		return nil
+	}
+
+	var events []PlayerEvent
+	for _, player := range list {
+		if _, ok := previous[player.ID]; !ok {
+			events = append(events, PlayerEvent{PlayerJoined, player, now})
+		}
+	}
+	for id, player := range previous {
+		if _, ok := current[id]; !ok {
+			events = append(events, PlayerEvent{PlayerLeft, player, now})
+		}
+	}
+
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+
+	return nil
+}
+
+// EventPrinter prints player events to the REPL while it is enabled.
+type EventPrinter struct {
+	out     io.Writer
+	enabled atomic.Bool
+}
+
+func NewEventPrinter(out io.Writer) *EventPrinter {
+	return &EventPrinter{out: out}
+}
+
+func (p *EventPrinter) Handle(event PlayerEvent) {
+	if p.enabled.Load() {
+		fmt.Fprintf(p.out, "[%s] %s\n", event.Time.Format(time.TimeOnly), event)
+	}
+}
+
+// EventLog appends player events to a file.
+type EventLog struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{w: w}
+}
+
+func (l *EventLog) Handle(event PlayerEvent) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fmt.Fprintf(l.w, "%s %s %s %s\n", event.Time.Format(time.RFC3339), event.Type, event.Player.ID, event.Player.Name)
+}
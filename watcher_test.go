@@ -0,0 +1,69 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"testing"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+func TestPlayerWatcherPoll(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionAdmin)
+	watcher := repl.watcher
+
+	var events []PlayerEvent
+	watcher.Subscribe(func(event PlayerEvent) {
+		events = append(events, event)
+	})
+
+	// The first poll is the baseline
+	if err := watcher.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("first poll should not emit events, got %v", events)
+	}
+
+	carol := rcon.Player{ID: "76561198000000003", Name: "Carol"}
+	server.RemovePlayer(alice.ID)
+	server.AddPlayer(carol)
+
+	if err := watcher.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %v", len(events), events)
+	}
+	if events[0].Type != PlayerJoined || events[0].Player.ID != carol.ID {
+		t.Errorf("expected Carol to join, got %v", events[0])
+	}
+	if events[1].Type != PlayerLeft || events[1].Player.ID != alice.ID {
+		t.Errorf("expected Alice to leave, got %v", events[1])
+	}
+	if len(watcher.Players()) != 2 {
+		t.Errorf("got %d players online, want 2", len(watcher.Players()))
+	}
+
+	if err := watcher.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("unchanged player list should not emit events, got %v", events[2:])
+	}
+}
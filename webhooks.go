@@ -0,0 +1,424 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WebhookJoin               = "join"
+	WebhookLeave              = "leave"
+	WebhookKick               = "kick"
+	WebhookBan                = "ban"
+	WebhookWhitelist          = "whitelist"
+	WebhookToggle             = "toggle"
+	WebhookConnectionLost     = "connection_lost"
+	WebhookConnectionRestored = "connection_restored"
+	WebhookRuleViolation      = "rule_violation"
+)
+
+const (
+	WebhookFormatJSON    = "json"
+	WebhookFormatDiscord = "discord"
+)
+
+const (
+	maxWebhookQueue = 1000
+	minWebhookDelay = time.Second
+	maxWebhookDelay = 5 * time.Minute
+	webhookInterval = time.Second
+	webhookTimeout  = 10 * time.Second
+)
+
+// WebhookConfig is an URL that events are posted to.
+type WebhookConfig struct {
+	URL string `json:"url"`
+
+	// Format is json or discord. Defaults to json.
+	Format string `json:"format"`
+
+	// Events limits the events that are sent to this webhook. An empty list
+	// means all events.
+	Events []string `json:"events"`
+}
+
+func (w *WebhookConfig) validate() error {
+	switch w.Format {
+	case "", WebhookFormatJSON, WebhookFormatDiscord:
+	default:
+		return fmt.Errorf("webhook \"%s\": unknown format \"%s\"", w.URL, w.Format)
+	}
+	for _, event := range w.Events {
+		switch event {
+		case WebhookJoin, WebhookLeave, WebhookKick, WebhookBan, WebhookWhitelist, WebhookToggle,
+			WebhookConnectionLost, WebhookConnectionRestored, WebhookRuleViolation:
+		default:
+			return fmt.Errorf("webhook \"%s\": unknown event \"%s\"", w.URL, event)
+		}
+	}
+	return nil
+}
+
+// WebhookEvent is the payload of the json format.
+type WebhookEvent struct {
+	Type       string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Server     string    `json:"server"`
+	Message    string    `json:"message"`
+	PlayerID   string    `json:"player_id,omitempty"`
+	PlayerName string    `json:"player_name,omitempty"`
+	Operator   string    `json:"operator,omitempty"`
+}
+
+type webhookDelivery struct {
+	ID       int64        `json:"id"`
+	URL      string       `json:"url"`
+	Format   string       `json:"format"`
+	Event    WebhookEvent `json:"event"`
+	Attempts int          `json:"attempts"`
+}
+
+// Notifier posts events to webhooks. Events are queued and saved to a file
+// until they were delivered, so nothing is lost if an endpoint is down or
+// the program is restarted.
+type Notifier struct {
+	*Poller
+	webhooks []WebhookConfig
+	server   string
+	path     string
+	names    func(playerID string) string
+	log      func(string)
+	http     *http.Client
+
+	mutex   sync.Mutex
+	queue   []webhookDelivery
+	nextID  int64
+	delay   map[string]time.Duration
+	retryAt map[string]time.Time
+}
+
+// NewNotifier creates a notifier and loads the queue from path. names is used
+// to look up the names of players that only appear with their ID.
+func NewNotifier(webhooks []WebhookConfig, server, path string, names func(playerID string) string, log func(string)) (*Notifier, error) {
+	for i := range webhooks {
+		if err := webhooks[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	n := &Notifier{
+		webhooks: webhooks,
+		server:   server,
+		path:     path,
+		names:    names,
+		log:      log,
+		http:     &http.Client{Timeout: webhookTimeout},
+		delay:    make(map[string]time.Duration),
+		retryAt:  make(map[string]time.Time),
+	}
+	n.Poller = NewPoller(webhookInterval, n.Poll)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &n.queue); err != nil {
+			return nil, fmt.Errorf("invalid webhook queue %s: %w", path, err)
+		}
+	}
+	for _, delivery := range n.queue {
+		n.nextID = max(n.nextID, delivery.ID+1)
+	}
+
+	return n, nil
+}
+
+// save writes the queue to disk. The mutex must be held.
+func (n *Notifier) save() error {
+	data, err := json.Marshal(n.queue)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(n.path, data, 0600)
+}
+
+// Notify queues event for every webhook that wants it.
+func (n *Notifier) Notify(event WebhookEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Server = n.server
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for _, webhook := range n.webhooks {
+		if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, event.Type) {
+			continue
+		}
+		n.queue = append(n.queue, webhookDelivery{ID: n.nextID, URL: webhook.URL, Format: webhook.Format, Event: event})
+		n.nextID++
+	}
+	if len(n.queue) > maxWebhookQueue {
+		n.log(fmt.Sprintf("Webhook queue is full, dropping %d events", len(n.queue)-maxWebhookQueue))
+		n.queue = n.queue[len(n.queue)-maxWebhookQueue:]
+	}
+	if err := n.save(); err != nil {
+		n.log(fmt.Sprintf("Cannot save webhook queue: %v", err))
+	}
+}
+
+// Pending returns the number of events that have not been delivered yet.
+func (n *Notifier) Pending() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return len(n.queue)
+}
+
+// Poll delivers queued events. Events for the same URL are delivered in
+// order, so if one of them fails, the others for that URL have to wait.
+func (n *Notifier) Poll() error {
+	n.mutex.Lock()
+	queue := slices.Clone(n.queue)
+	n.mutex.Unlock()
+
+	now := time.Now()
+	waiting := make(map[string]bool)
+	done := make(map[int64]bool)
+	failed := make(map[int64]bool)
+
+	for _, delivery := range queue {
+		if waiting[delivery.URL] || now.Before(n.retryAfter(delivery.URL)) {
+			waiting[delivery.URL] = true
+			continue
+		}
+
+		retry, err := n.deliver(delivery)
+		switch {
+		case err == nil:
+			done[delivery.ID] = true
+			n.backoff(delivery.URL, false)
+		case !retry:
+			n.log(fmt.Sprintf("Dropping %s event for %s: %v", delivery.Event.Type, delivery.URL, err))
+			done[delivery.ID] = true
+		default:
+			waiting[delivery.URL] = true
+			failed[delivery.ID] = true
+			delay := n.backoff(delivery.URL, true)
+			if delivery.Attempts == 0 {
+				n.log(fmt.Sprintf("Cannot deliver %s event to %s, retrying in %s: %v", delivery.Event.Type, delivery.URL, delay, err))
+			}
+		}
+	}
+
+	if len(done) == 0 && len(failed) == 0 {
+		return nil
+	}
+
+	// Events may have been queued in the meantime, so the queue has to be
+	// updated instead of replaced
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.queue = slices.DeleteFunc(n.queue, func(d webhookDelivery) bool { return done[d.ID] })
+	for i := range n.queue {
+		if failed[n.queue[i].ID] {
+			n.queue[i].Attempts++
+		}
+	}
+	return n.save()
+}
+
+func (n *Notifier) retryAfter(url string) time.Time {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.retryAt[url]
+}
+
+// backoff doubles the delay for url after a failure and resets it after a
+// success. It returns the new delay.
+func (n *Notifier) backoff(url string, failed bool) time.Duration {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if !failed {
+		delete(n.delay, url)
+		delete(n.retryAt, url)
+		return 0
+	}
+
+	delay := min(max(n.delay[url]*2, minWebhookDelay), maxWebhookDelay)
+	n.delay[url] = delay
+	n.retryAt[url] = time.Now().Add(delay)
+	return delay
+}
+
+// deliver posts a single event. retry is false if the endpoint rejected the
+// event and trying again won't help.
+func (n *Notifier) deliver(delivery webhookDelivery) (retry bool, err error) {
+	var payload any = delivery.Event
+	if delivery.Format == WebhookFormatDiscord {
+		payload = map[string]string{
+			"username": "PteroPrompt",
+			"content":  fmt.Sprintf("**%s**: %s", delivery.Event.Server, delivery.Event.Message),
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := n.http.Post(delivery.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, errors.New(resp.Status)
+	default:
+		return false, errors.New(resp.Status)
+	}
+}
+
+func (n *Notifier) HandlePlayerEvent(event PlayerEvent) {
+	e := WebhookEvent{
+		Time:       event.Time,
+		PlayerID:   event.Player.ID,
+		PlayerName: event.Player.Name,
+	}
+	switch event.Type {
+	case PlayerJoined:
+		e.Type = WebhookJoin
+		e.Message = fmt.Sprintf("%s joined the server", event.Player.Name)
+	case PlayerLeft:
+		e.Type = WebhookLeave
+		e.Message = fmt.Sprintf("%s left the server", event.Player.Name)
+	}
+	n.Notify(e)
+}
+
+// HandleAuditEntry turns kicks, bans, whitelist changes and toggles into
+// events.
+// Everything that changes the server goes through the audit log, no matter
+// which command caused it.
+func (n *Notifier) HandleAuditEntry(entry AuditEntry) {
+	if strings.HasPrefix(entry.Result, "error") {
+		return
+	}
+
+	// The rule engine reports its kicks through HandleViolation, with the
+	// rule that was broken
+	if entry.Command == "rules" {
+		return
+	}
+
+	e := WebhookEvent{Time: entry.Time, Operator: entry.Operator}
+	players := make([]string, len(entry.PlayerIDs))
+	for i, id := range entry.PlayerIDs {
+		players[i] = id
+		if name := n.names(id); name != "" {
+			players[i] = fmt.Sprintf("%s (%s)", name, id)
+		}
+	}
+	if len(entry.PlayerIDs) == 1 {
+		e.PlayerID = entry.PlayerIDs[0]
+		e.PlayerName = n.names(e.PlayerID)
+	}
+
+	switch entry.Action {
+	case "KickPlayer":
+		e.Type = WebhookKick
+		e.Message = fmt.Sprintf("%s kicked %s", entry.Operator, strings.Join(players, ", "))
+		if len(entry.Args) > 1 && entry.Args[1] != "" {
+			e.Message += ": " + entry.Args[1]
+		}
+	case "BanPlayer":
+		// The arguments are the name, the ID, the reason and the minutes
+		if len(entry.Args) < 4 {
+			return
+		}
+		duration := "permanently"
+		if minutes, err := strconv.Atoi(entry.Args[3]); err == nil && minutes > 0 {
+			duration = "for " + formatDuration(time.Duration(minutes)*time.Minute)
+		}
+		e.Type = WebhookBan
+		e.Message = fmt.Sprintf("%s banned %s %s", entry.Operator, strings.Join(players, ", "), duration)
+		if entry.Args[2] != "" {
+			e.Message += ": " + entry.Args[2]
+		}
+	case "AddWhitelistID":
+		e.Type = WebhookWhitelist
+		e.Message = fmt.Sprintf("%s added %s to the whitelist", entry.Operator, strings.Join(players, ", "))
+	case "RemoveWhitelistID":
+		e.Type = WebhookWhitelist
+		e.Message = fmt.Sprintf("%s removed %s from the whitelist", entry.Operator, strings.Join(players, ", "))
+	case "ToggleWhitelist":
+		e.Type = WebhookWhitelist
+		e.Message = fmt.Sprintf("%s turned the whitelist %s", entry.Operator, entry.Result)
+	case "ToggleGlobalChat":
+		e.Type = WebhookToggle
+		e.Message = fmt.Sprintf("%s turned the global chat %s", entry.Operator, entry.Result)
+	case "ToggleHumans":
+		e.Type = WebhookToggle
+		e.Message = fmt.Sprintf("%s turned humans %s", entry.Operator, entry.Result)
+	case "ToggleAI":
+		e.Type = WebhookToggle
+		e.Message = fmt.Sprintf("%s turned AI spawning %s", entry.Operator, entry.Result)
+	default:
+		return
+	}
+	n.Notify(e)
+}
+
+func (n *Notifier) HandleViolation(v Violation, kicked bool) {
+	action := "was warned"
+	if kicked {
+		action = "was kicked"
+	}
+	n.Notify(WebhookEvent{
+		Type:       WebhookRuleViolation,
+		Message:    fmt.Sprintf("%s broke the rule \"%s\" and %s", v.Player.Name, v.Rule.Name, action),
+		PlayerID:   v.Player.ID,
+		PlayerName: v.Player.Name,
+	})
+}
+
+func (n *Notifier) HandleConnection(connected bool, err error) {
+	if connected {
+		n.Notify(WebhookEvent{Type: WebhookConnectionRestored, Message: "The connection to the server was restored"})
+	} else {
+		n.Notify(WebhookEvent{Type: WebhookConnectionLost, Message: fmt.Sprintf("Lost the connection to the server: %v", err)})
+	}
+}
@@ -0,0 +1,254 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// webhookEndpoint records every request. While fail is true, it responds
+// with an error.
+type webhookEndpoint struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	fail     bool
+	payloads []map[string]any
+}
+
+func newWebhookEndpoint(t *testing.T) *webhookEndpoint {
+	t.Helper()
+	e := &webhookEndpoint{}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		if e.fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		e.payloads = append(e.payloads, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *webhookEndpoint) setFail(fail bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.fail = fail
+}
+
+func (e *webhookEndpoint) received() []map[string]any {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.payloads
+}
+
+func newTestNotifier(t *testing.T, path string, webhooks ...WebhookConfig) *Notifier {
+	t.Helper()
+	names := func(id string) string {
+		if id == bob.ID {
+			return bob.Name
+		}
+		return ""
+	}
+	n, err := NewNotifier(webhooks, "Fake Server", path, names, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWebhookFormats(t *testing.T) {
+	generic := newWebhookEndpoint(t)
+	discord := newWebhookEndpoint(t)
+	n := newTestNotifier(t, filepath.Join(t.TempDir(), "webhooks.json"),
+		WebhookConfig{URL: generic.URL},
+		WebhookConfig{URL: discord.URL, Format: WebhookFormatDiscord, Events: []string{WebhookKick}},
+	)
+
+	n.HandlePlayerEvent(PlayerEvent{PlayerJoined, alice, time.Now()})
+	n.HandleAuditEntry(AuditEntry{Operator: "tester", Action: "KickPlayer", Args: []string{bob.ID, "Bye"}, PlayerIDs: []string{bob.ID}, Result: "ok"})
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := generic.received()
+	if len(payloads) != 2 {
+		t.Fatalf("got %d generic payloads, want 2: %v", len(payloads), payloads)
+	}
+	if payloads[0]["event"] != WebhookJoin || payloads[0]["player_name"] != "Alice" || payloads[0]["server"] != "Fake Server" {
+		t.Errorf("unexpected join payload %v", payloads[0])
+	}
+	if payloads[1]["message"] != "tester kicked Bob ("+bob.ID+"): Bye" {
+		t.Errorf("unexpected kick payload %v", payloads[1])
+	}
+
+	// Discord only wants kicks
+	payloads = discord.received()
+	if len(payloads) != 1 || payloads[0]["content"] != "**Fake Server**: tester kicked Bob ("+bob.ID+"): Bye" {
+		t.Errorf("unexpected discord payloads %v", payloads)
+	}
+	if n.Pending() != 0 {
+		t.Errorf("got %d pending events, want 0", n.Pending())
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	endpoint := newWebhookEndpoint(t)
+	endpoint.setFail(true)
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	n := newTestNotifier(t, path, WebhookConfig{URL: endpoint.URL})
+
+	n.HandleConnection(false, nil)
+	n.HandleConnection(true, nil)
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n.Pending() != 2 {
+		t.Fatalf("got %d pending events, want 2", n.Pending())
+	}
+
+	// The queue survives a restart
+	n = newTestNotifier(t, path, WebhookConfig{URL: endpoint.URL})
+	if n.Pending() != 2 {
+		t.Fatalf("got %d pending events after loading the queue, want 2", n.Pending())
+	}
+
+	endpoint.setFail(false)
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := endpoint.received()
+	if len(payloads) != 2 || payloads[0]["event"] != WebhookConnectionLost || payloads[1]["event"] != WebhookConnectionRestored {
+		t.Errorf("expected the events in order, got %v", payloads)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	endpoint := newWebhookEndpoint(t)
+	endpoint.setFail(true)
+	n := newTestNotifier(t, filepath.Join(t.TempDir(), "webhooks.json"), WebhookConfig{URL: endpoint.URL})
+
+	n.HandlePlayerEvent(PlayerEvent{PlayerLeft, alice, time.Now()})
+	n.Poll()
+	endpoint.setFail(false)
+
+	// The endpoint works again, but the notifier waits for the backoff
+	n.Poll()
+	if len(endpoint.received()) != 0 {
+		t.Errorf("expected the notifier to wait before trying again")
+	}
+}
+
+func TestWebhookFromCommands(t *testing.T) {
+	endpoint := newWebhookEndpoint(t)
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	n := newTestNotifier(t, filepath.Join(t.TempDir(), "webhooks.json"), WebhookConfig{URL: endpoint.URL, Events: []string{WebhookToggle, WebhookWhitelist}})
+	repl.auditLog.Subscribe(n.HandleAuditEntry)
+
+	execute(t, repl, "toggle_gc")
+	execute(t, repl, "whitelist add 123")
+	execute(t, repl, "kick Bob")
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := endpoint.received()
+	if len(payloads) != 2 {
+		t.Fatalf("got %d payloads, want 2: %v", len(payloads), payloads)
+	}
+	if payloads[0]["message"] != "tester turned the global chat off" {
+		t.Errorf("unexpected toggle payload %v", payloads[0])
+	}
+	if payloads[1]["message"] != "tester added 123 to the whitelist" {
+		t.Errorf("unexpected whitelist payload %v", payloads[1])
+	}
+}
+
+func TestWebhookBan(t *testing.T) {
+	endpoint := newWebhookEndpoint(t)
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	n := newTestNotifier(t, filepath.Join(t.TempDir(), "webhooks.json"), WebhookConfig{URL: endpoint.URL, Events: []string{WebhookBan}})
+	repl.auditLog.Subscribe(n.HandleAuditEntry)
+
+	execute(t, repl, "ban Bob --minutes 60 Camping, again")
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := endpoint.received()
+	if len(payloads) != 1 || payloads[0]["message"] != "tester banned Bob ("+bob.ID+") for 1h 0m: Camping again" {
+		t.Errorf("got payloads %v, want a single ban", payloads)
+	}
+
+	entries, err := repl.auditLog.Entries(AuditFilter{Action: "ban"}.Match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !slices.Equal(entries[0].PlayerIDs, []string{bob.ID}) {
+		t.Errorf("got audit entries %+v, want the ban", entries)
+	}
+}
+
+func TestWebhookRuleKick(t *testing.T) {
+	endpoint := newWebhookEndpoint(t)
+	repl, _ := newTestRepl(t, PermissionAdmin)
+	n := newTestNotifier(t, filepath.Join(t.TempDir(), "webhooks.json"), WebhookConfig{URL: endpoint.URL})
+	repl.auditLog.Subscribe(n.HandleAuditEntry)
+
+	rule := RuleConfig{Name: "no stegos", Type: RuleClassLimit, Classes: []rcon.DinoClass{rcon.Stegosaurus}}
+	engine, err := NewRuleEngine(repl.wrapClient("rules"), RulesConfig{Rules: []RuleConfig{rule}}, nil, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.OnViolation(n.HandleViolation)
+
+	if err := engine.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The kick is only reported once, by the rule engine
+	payloads := endpoint.received()
+	if len(payloads) != 1 || payloads[0]["event"] != WebhookRuleViolation {
+		t.Errorf("got payloads %v, want a single rule violation", payloads)
+	}
+}
+
+func TestInvalidWebhook(t *testing.T) {
+	_, err := NewNotifier([]WebhookConfig{{URL: "http://localhost", Events: []string{"explosion"}}}, "", filepath.Join(t.TempDir(), "webhooks.json"), nil, nil)
+	if err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// ZonePoint is a position on the map in the coordinates that the game shows.
+type ZonePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// gamePoint converts a location of a player to the coordinates that the game
+// shows. The RCON library swaps X and Y.
+func gamePoint(loc rcon.Location) ZonePoint {
+	return ZonePoint{X: loc.Y, Y: loc.X}
+}
+
+// Zone is a named area of the map. It is either a box between two opposite
+// corners or a polygon.
+type Zone struct {
+	Name    string      `json:"name"`
+	From    *ZonePoint  `json:"from"`
+	To      *ZonePoint  `json:"to"`
+	Polygon []ZonePoint `json:"polygon"`
+}
+
+// ZoneGrid divides the map into squares like A1, B1 and so on. From is the
+// corner of A1 and To the opposite corner of the map.
+type ZoneGrid struct {
+	From    ZonePoint `json:"from"`
+	To      ZonePoint `json:"to"`
+	Columns int       `json:"columns"`
+	Rows    int       `json:"rows"`
+}
+
+// ZoneMap is read from the zone file. It names the areas of a map, so that
+// locations can be shown as "Highlands, D5" instead of coordinates.
+type ZoneMap struct {
+	Map   string   `json:"map"`
+	Grid  ZoneGrid `json:"grid"`
+	Zones []Zone   `json:"zones"`
+}
+
+// LoadZoneMap reads the zone file at path.
+func LoadZoneMap(path string) (*ZoneMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m ZoneMap
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zone file %s: %w", path, err)
+	}
+
+	// The grid is optional, but if it's there, it has to have squares
+	if g := m.Grid; g != (ZoneGrid{}) && (g.Columns < 1 || g.Columns > 26 || g.Rows < 1) {
+		return nil, fmt.Errorf("invalid zone file %s: the grid needs 1 to 26 columns and at least 1 row", path)
+	}
+	for i := range m.Zones {
+		z := &m.Zones[i]
+		isBox := z.From != nil && z.To != nil
+		if isBox == (len(z.Polygon) > 0) {
+			return nil, fmt.Errorf("invalid zone file %s: zone \"%s\" needs either from and to or a polygon", path, z.Name)
+		}
+		if !isBox && len(z.Polygon) < 3 {
+			return nil, fmt.Errorf("invalid zone file %s: the polygon of zone \"%s\" needs at least 3 points", path, z.Name)
+		}
+	}
+
+	return &m, nil
+}
+
+// contains returns true if p is inside the zone.
+func (z *Zone) contains(p ZonePoint) bool {
+	if z.From != nil && z.To != nil {
+		return p.X >= min(z.From.X, z.To.X) && p.X <= max(z.From.X, z.To.X) &&
+			p.Y >= min(z.From.Y, z.To.Y) && p.Y <= max(z.From.Y, z.To.Y)
+	}
+
+	// Count how often a ray from p crosses the edges of the polygon
+	inside := false
+	for i, j := 0, len(z.Polygon)-1; i < len(z.Polygon); j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distance returns how far p is from the edge of the zone.
+func (z *Zone) distance(p ZonePoint) float64 {
+	edges := z.Polygon
+	if z.From != nil && z.To != nil {
+		edges = []ZonePoint{*z.From, {X: z.To.X, Y: z.From.Y}, *z.To, {X: z.From.X, Y: z.To.Y}}
+	}
+
+	d := math.Inf(1)
+	for i, j := 0, len(edges)-1; i < len(edges); j, i = i, i+1 {
+		d = min(d, segmentDistance(p, edges[j], edges[i]))
+	}
+	return d
+}
+
+// segmentDistance returns the distance between p and the line from a to b.
+func segmentDistance(p, a, b ZonePoint) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = min(max(((p.X-a.X)*dx+(p.Y-a.Y)*dy)/length, 0), 1)
+	}
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
+
+// Zone returns the name of the zone that loc is in. If it isn't in any zone,
+// the nearest one is returned and inside is false. Zones that come first in
+// the file win if they overlap.
+func (m *ZoneMap) Zone(loc rcon.Location) (name string, inside bool) {
+	p := gamePoint(loc)
+	nearest := math.Inf(1)
+	for i := range m.Zones {
+		z := &m.Zones[i]
+		if z.contains(p) {
+			return z.Name, true
+		}
+		if d := z.distance(p); d < nearest {
+			nearest = d
+			name = z.Name
+		}
+	}
+	return name, false
+}
+
+// GridReference returns the square of the grid that loc is in, like "D5", or
+// an empty string if there is no grid or loc is outside of it.
+func (m *ZoneMap) GridReference(loc rcon.Location) string {
+	g := m.Grid
+	if g.Columns == 0 || g.Rows == 0 || g.From.X == g.To.X || g.From.Y == g.To.Y {
+		return ""
+	}
+
+	p := gamePoint(loc)
+	column := int(math.Floor((p.X - g.From.X) / (g.To.X - g.From.X) * float64(g.Columns)))
+	row := int(math.Floor((p.Y - g.From.Y) / (g.To.Y - g.From.Y) * float64(g.Rows)))
+	if column < 0 || column >= g.Columns || row < 0 || row >= g.Rows {
+		return ""
+	}
+	return fmt.Sprintf("%c%d", 'A'+column, row+1)
+}
+
+// Describe returns where loc is in words, like "Highlands, D5" or
+// "near Swamps, F7". A nil map describes nothing.
+func (m *ZoneMap) Describe(loc rcon.Location) string {
+	if m == nil {
+		return ""
+	}
+
+	description, inside := m.Zone(loc)
+	if description != "" && !inside {
+		description = "near " + description
+	}
+	if grid := m.GridReference(loc); grid != "" {
+		if description != "" {
+			description += ", "
+		}
+		description += grid
+	}
+	return description
+}
@@ -0,0 +1,207 @@
+/*
+Copyright (C) 2025  Marius Becker
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	rcon "github.com/butt4cak3/theislercon"
+)
+
+// at returns the location of a player at the coordinates that the game shows.
+func at(x, y float64) rcon.Location {
+	return rcon.Location{X: y, Y: x}
+}
+
+const testZoneFile = `{
+	"map": "Gateway",
+	"grid": {"from": {"x": -1000, "y": -1000}, "to": {"x": 1000, "y": 1000}, "columns": 10, "rows": 10},
+	"zones": [
+		{"name": "Water Access", "from": {"x": 0, "y": 0}, "to": {"x": 100, "y": 100}},
+		{"name": "Highlands", "from": {"x": -1000, "y": -1000}, "to": {"x": 0, "y": 0}},
+		{"name": "Swamps", "polygon": [{"x": 500, "y": 500}, {"x": 900, "y": 500}, {"x": 700, "y": 900}]}
+	]
+}`
+
+func loadTestZones(t *testing.T) *ZoneMap {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "zones.json")
+	os.WriteFile(path, []byte(testZoneFile), 0644)
+	zones, err := LoadZoneMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zones
+}
+
+func TestZones(t *testing.T) {
+	zones := loadTestZones(t)
+
+	tests := []struct {
+		location rcon.Location
+		want     string
+	}{
+		{at(50, 50), "Water Access, F6"},
+		{at(-500, -500), "Highlands, C3"},
+		{at(700, 600), "Swamps, I9"},
+		// Inside the bounding box of the triangle, but not the triangle
+		{at(550, 850), "near Swamps, H10"},
+		{at(300, -500), "near Highlands, G3"},
+		{at(5000, 5000), "near Swamps"},
+	}
+	for _, test := range tests {
+		if got := zones.Describe(test.location); got != test.want {
+			t.Errorf("Describe(%v) = %q, want %q", test.location, got, test.want)
+		}
+	}
+
+	var none *ZoneMap
+	if got := none.Describe(at(50, 50)); got != "" {
+		t.Errorf("a nil zone map described %q", got)
+	}
+}
+
+func TestInvalidZoneFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.json")
+	os.WriteFile(path, []byte(`{"zones": [{"name": "Line", "polygon": [{"x": 0, "y": 0}, {"x": 1, "y": 1}]}]}`), 0644)
+	if _, err := LoadZoneMap(path); err == nil || !strings.Contains(err.Error(), "at least 3 points") {
+		t.Errorf("got %v, want an error about the polygon", err)
+	}
+}
+
+func TestInvalidGrid(t *testing.T) {
+	for _, grid := range []string{
+		`{"columns": 0, "rows": 10, "to": {"x": 1000, "y": 1000}}`,
+		`{"columns": 27, "rows": 10}`,
+		`{"columns": 10, "rows": 0}`,
+	} {
+		path := filepath.Join(t.TempDir(), "zones.json")
+		os.WriteFile(path, []byte(`{"grid": `+grid+`}`), 0644)
+		if _, err := LoadZoneMap(path); err == nil || !strings.Contains(err.Error(), "1 to 26 columns") {
+			t.Errorf("grid %s: got %v, want an error about the grid", grid, err)
+		}
+	}
+
+	// Without a grid, the map just has no squares
+	path := filepath.Join(t.TempDir(), "zones.json")
+	os.WriteFile(path, []byte(`{"zones": []}`), 0644)
+	if _, err := LoadZoneMap(path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInfoShowsZone(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionReadOnly)
+	repl.zones = loadTestZones(t)
+	server.AddPlayer(rcon.Player{ID: "76561198000000007", Name: "Rexy", Location: at(-500, -500)})
+
+	output := execute(t, repl, "info Rexy")
+	if !strings.Contains(output, "Zone:     Highlands, C3") {
+		t.Errorf("got %q", output)
+	}
+
+	output = execute(t, repl, "players")
+	if !strings.Contains(output, "Rexy                     Highlands, C3") {
+		t.Errorf("got %q", output)
+	}
+}
+
+func TestRuleMessagesShowZone(t *testing.T) {
+	var logs []string
+	rule := RuleConfig{Name: "no camping", Type: RuleZone, To: RulePoint{ZonePoint: ZonePoint{X: 100, Y: 100}}, Warnings: 1}
+	engine, err := NewRuleEngine(nil, RulesConfig{DryRun: true, Rules: []RuleConfig{rule}}, loadTestZones(t), func(message string) {
+		logs = append(logs, message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player := rcon.Player{ID: "1", Name: "Rexy", Location: at(50, 50)}
+	if err := engine.enforce(Violation{&engine.config.Rules[0], player}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "Rexy (1) at Water Access, F6") {
+		t.Errorf("logs = %q", logs)
+	}
+}
+
+// classSelectionClient leaves a player out of the player data, like the
+// server does while they are choosing a class.
+type classSelectionClient struct {
+	Client
+	choosing string
+}
+
+func (c *classSelectionClient) GetPlayerData() ([]rcon.Player, error) {
+	players, err := c.Client.GetPlayerData()
+	return slices.DeleteFunc(players, func(p rcon.Player) bool { return p.ID == c.choosing }), err
+}
+
+func TestPlayersInClassSelection(t *testing.T) {
+	repl, server := newTestRepl(t, PermissionReadOnly)
+	zones := loadTestZones(t)
+	server.SetPlayers(rcon.Player{ID: alice.ID, Name: alice.Name, Location: at(-500, -500)}, bob)
+
+	client := &classSelectionClient{repl.wrapClient("players"), bob.ID}
+	output := captureOutput(t, func() {
+		if err := playerListCommand(client, zones, nil); err != nil {
+			t.Error(err)
+		}
+	})
+	if !strings.Contains(output, "Alice                    Highlands, C3\n") || !strings.Contains(output, "    Bob\n") {
+		t.Errorf("got %q, want Alice with a zone and Bob without", output)
+	}
+}
+
+func TestRulesAndZonesUseTheSameCoordinates(t *testing.T) {
+	const box = `"from": {"x": 0, "y": 0}, "to": {"x": 100, "y": 1000}`
+
+	path := filepath.Join(t.TempDir(), "zones.json")
+	os.WriteFile(path, []byte(`{"zones": [{"name": "Box", `+box+`}]}`), 0644)
+	zones, err := LoadZoneMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rule RuleConfig
+	if err := json.Unmarshal([]byte(`{"name": "box", "type": "zone", `+box+`}`), &rule); err != nil {
+		t.Fatal(err)
+	}
+
+	inside := rcon.Player{ID: "1", Location: at(50, 500)}
+	outside := rcon.Player{ID: "2", Location: at(500, 50)}
+
+	if got := zones.Describe(inside.Location); got != "Box" {
+		t.Errorf("zone of the player inside = %q, want Box", got)
+	}
+	if got := zones.Describe(outside.Location); got != "near Box" {
+		t.Errorf("zone of the player outside = %q, want near Box", got)
+	}
+	result := checkRule(t, rule, []rcon.Player{inside, outside})
+	if ids := violators(result[0]); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("got violations %v, want [1]", ids)
+	}
+	if got, want := rule.Describe(), "between 0, 0 and 100, 1000"; !strings.Contains(got, want) {
+		t.Errorf("rule description %q doesn't contain %q", got, want)
+	}
+}